@@ -0,0 +1,104 @@
+package anonymization
+
+// Prefix-preserving IP address anonymization as proposed by Xu et al.
+// "Prefix-Preserving IP Address Anonymization: Measurement-based Security Evaluation and a New Cryptography-based Scheme" (Crypto-PAn).
+// Two addresses sharing a prefix of n bits will share a prefix of exactly n bits after anonymization.
+// IPv6 addresses are anonymized in the same way, using all 128 bits.
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+)
+
+// KeySize is the size of a Crypto-PAn key in bytes (16 bytes AES key, 16 bytes pad)
+const KeySize = 32
+
+// CryptoPAn anonymizes IP addresses with a secret key. It is safe for concurrent use.
+// Anonymized addresses are cached (one entry per distinct address), since every address requires one AES encryption per bit.
+type CryptoPAn struct {
+	block cipher.Block
+	pad   [aes.BlockSize]byte
+
+	cacheMutex sync.RWMutex
+	cache      map[string][]byte
+}
+
+// NewCryptoPAn creates a new anonymizer with a 32 byte key.
+// The first 16 bytes are used as AES key, the last 16 bytes are encrypted to create the pad.
+func NewCryptoPAn(key []byte) (*CryptoPAn, error) {
+	if len(key) != KeySize {
+		return nil, errors.New("crypto-pan key must be exactly 32 bytes long")
+	}
+	block, err := aes.NewCipher(key[:aes.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	c := &CryptoPAn{block: block, cache: make(map[string][]byte)}
+	block.Encrypt(c.pad[:], key[aes.BlockSize:])
+	return c, nil
+}
+
+// LoadCryptoPAnKey reads a key from a file. The file must either contain exactly 32 bytes
+// or 64 hexadecimal characters (surrounding whitespace is ignored).
+func LoadCryptoPAnKey(filename string) ([]byte, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == KeySize {
+		return content, nil
+	}
+	trimmed := strings.TrimSpace(string(content))
+	if len(trimmed) == 2*KeySize {
+		return hex.DecodeString(trimmed)
+	}
+	return nil, errors.New("crypto-pan key file must contain 32 bytes or 64 hex characters: " + filename)
+}
+
+// Anonymize returns the anonymized address. ip must be 4 (IPv4) or 16 (IPv6) bytes long.
+// The returned slice is shared with the cache and must not be modified.
+func (c *CryptoPAn) Anonymize(ip []byte) []byte {
+	c.cacheMutex.RLock()
+	anonymized, exists := c.cache[string(ip)]
+	c.cacheMutex.RUnlock()
+	if exists {
+		return anonymized
+	}
+	anonymized = c.anonymize(ip)
+	c.cacheMutex.Lock()
+	c.cache[string(ip)] = anonymized
+	c.cacheMutex.Unlock()
+	return anonymized
+}
+
+// anonymize computes the anonymized address bit by bit
+func (c *CryptoPAn) anonymize(ip []byte) []byte {
+	var input, output [aes.BlockSize]byte
+	var otp [net.IPv6len]byte
+	numBits := len(ip) * 8
+
+	for pos := 0; pos < numBits; pos++ {
+		// Input is the first pos bits of the address, padded with the remaining bits of the pad
+		input = c.pad
+		copy(input[:pos/8], ip[:pos/8])
+		if pos%8 != 0 {
+			mask := byte(0xff) << uint(8-pos%8)
+			input[pos/8] = (ip[pos/8] & mask) | (c.pad[pos/8] &^ mask)
+		}
+		c.block.Encrypt(output[:], input[:])
+		// The most significant bit of the encrypted block is the one-time-pad bit for pos
+		otp[pos/8] |= (output[0] >> 7) << uint(7-pos%8)
+	}
+
+	anonymized := make([]byte, len(ip))
+	for i := range ip {
+		anonymized[i] = ip[i] ^ otp[i]
+	}
+	return anonymized
+}
@@ -0,0 +1,87 @@
+package anonymization
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// referenceKey is the key of the sample trace published with the Crypto-PAn reference implementation
+var referenceKey = []byte{21, 34, 23, 141, 51, 164, 207, 128, 19, 10, 91, 22, 73, 144, 125, 16,
+	216, 152, 143, 131, 121, 121, 101, 39, 98, 87, 76, 45, 42, 132, 34, 2}
+
+func TestAnonymizeIPv4(t *testing.T) {
+	// Excerpt of the sample trace of the reference implementation
+	tests := []struct {
+		address    string
+		anonymized string
+	}{
+		{"128.11.68.132", "135.242.180.132"},
+		{"129.118.74.4", "134.136.186.123"},
+		{"130.132.252.244", "133.68.164.234"},
+		{"141.223.7.43", "141.167.8.160"},
+		{"141.233.145.108", "141.129.237.235"},
+		{"152.163.225.39", "151.140.114.167"},
+		{"156.29.3.236", "147.225.12.42"},
+		{"165.247.96.84", "162.9.99.234"},
+		{"166.107.77.190", "160.132.178.185"},
+		{"192.102.249.13", "252.138.62.131"},
+	}
+	c, err := NewCryptoPAn(referenceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			// The second call is answered from the cache
+			for i := 0; i < 2; i++ {
+				if anonymized := net.IP(c.Anonymize(net.ParseIP(test.address).To4())).String(); anonymized != test.anonymized {
+					t.Errorf("Anonymize(%s) = %s, want %s", test.address, anonymized, test.anonymized)
+				}
+			}
+		})
+	}
+}
+
+// commonPrefixLength returns the number of leading bits both addresses share
+func commonPrefixLength(a, b []byte) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			n := i * 8
+			for ; x&0x80 == 0; x <<= 1 {
+				n++
+			}
+			return n
+		}
+	}
+	return len(a) * 8
+}
+
+func TestAnonymizeIPv6(t *testing.T) {
+	c, err := NewCryptoPAn(referenceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first 32 bits are anonymized like the IPv4 address 128.11.68.132 -> 135.242.180.132
+	anonymized := c.Anonymize(net.ParseIP("800b:4484::1"))
+	if !bytes.Equal(anonymized[:4], net.ParseIP("135.242.180.132").To4()) {
+		t.Errorf("Anonymize(800b:4484::1) = %s, want the prefix 87f2:b484", net.IP(anonymized))
+	}
+
+	// The anonymization is prefix-preserving over all 128 bits
+	addresses := []string{"2001:db8::1", "2001:db8::2", "2001:db8:0:1::1", "2001:db8:ffff::1", "2001:db9::1", "fe80::1", "::1"}
+	for _, a := range addresses {
+		for _, b := range addresses {
+			ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+			if got, want := commonPrefixLength(c.Anonymize(ipA), c.Anonymize(ipB)), commonPrefixLength(ipA, ipB); got != want {
+				t.Errorf("%s and %s share %d bits after anonymization, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestNewCryptoPAnKeySize(t *testing.T) {
+	if _, err := NewCryptoPAn(referenceKey[:16]); err == nil {
+		t.Error("NewCryptoPAn accepted a 16 byte key")
+	}
+}
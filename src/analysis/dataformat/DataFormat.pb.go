@@ -173,6 +173,7 @@ type Flow struct {
 	ServerAddress        uint64        `protobuf:"varint,1,opt,name=server_address,json=serverAddress,proto3" json:"server_address,omitempty"`
 	NumRrp               int64         `protobuf:"varint,2,opt,name=num_rrp,json=numRrp,proto3" json:"num_rrp,omitempty"`
 	InterReq             *Distribution `protobuf:"bytes,3,opt,name=inter_req,json=interReq,proto3" json:"inter_req,omitempty"`
+	ServerIpAddress      string        `protobuf:"bytes,4,opt,name=server_ip_address,json=serverIpAddress,proto3" json:"server_ip_address,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
 	XXX_unrecognized     []byte        `json:"-"`
 	XXX_sizecache        int32         `json:"-"`
@@ -224,6 +225,13 @@ func (m *Flow) GetInterReq() *Distribution {
 	return nil
 }
 
+func (m *Flow) GetServerIpAddress() string {
+	if m != nil {
+		return m.ServerIpAddress
+	}
+	return ""
+}
+
 type Flows struct {
 	Flows                []*Flow  `protobuf:"bytes,1,rep,name=flows,proto3" json:"flows,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -268,6 +276,7 @@ type Session struct {
 	NumServers           int64         `protobuf:"varint,2,opt,name=num_servers,json=numServers,proto3" json:"num_servers,omitempty"`
 	NumFlows             int64         `protobuf:"varint,3,opt,name=num_flows,json=numFlows,proto3" json:"num_flows,omitempty"`
 	InterFlow            *Distribution `protobuf:"bytes,4,opt,name=inter_flow,json=interFlow,proto3" json:"inter_flow,omitempty"`
+	ClientIpAddress      string        `protobuf:"bytes,5,opt,name=client_ip_address,json=clientIpAddress,proto3" json:"client_ip_address,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
 	XXX_unrecognized     []byte        `json:"-"`
 	XXX_sizecache        int32         `json:"-"`
@@ -326,6 +335,13 @@ func (m *Session) GetInterFlow() *Distribution {
 	return nil
 }
 
+func (m *Session) GetClientIpAddress() string {
+	if m != nil {
+		return m.ClientIpAddress
+	}
+	return ""
+}
+
 type Sessions struct {
 	Sessions             []*Session `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
@@ -369,6 +385,7 @@ type User struct {
 	ClientAddress        uint64        `protobuf:"varint,1,opt,name=client_address,json=clientAddress,proto3" json:"client_address,omitempty"`
 	NumSessions          int64         `protobuf:"varint,2,opt,name=num_sessions,json=numSessions,proto3" json:"num_sessions,omitempty"`
 	InterSession         *Distribution `protobuf:"bytes,3,opt,name=inter_session,json=interSession,proto3" json:"inter_session,omitempty"`
+	ClientIpAddress      string        `protobuf:"bytes,4,opt,name=client_ip_address,json=clientIpAddress,proto3" json:"client_ip_address,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
 	XXX_unrecognized     []byte        `json:"-"`
 	XXX_sizecache        int32         `json:"-"`
@@ -420,6 +437,13 @@ func (m *User) GetInterSession() *Distribution {
 	return nil
 }
 
+func (m *User) GetClientIpAddress() string {
+	if m != nil {
+		return m.ClientIpAddress
+	}
+	return ""
+}
+
 type Users struct {
 	Users                []*User  `protobuf:"bytes,1,rep,name=users,proto3" json:"users,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -474,33 +498,36 @@ func init() {
 func init() { proto.RegisterFile("DataFormat.proto", fileDescriptor_f338bfeebed1f6b5) }
 
 var fileDescriptor_f338bfeebed1f6b5 = []byte{
-	// 444 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x53, 0xd1, 0x6a, 0xd4, 0x40,
-	0x14, 0x25, 0x26, 0xdd, 0x6e, 0x6f, 0xb2, 0x75, 0x19, 0x1f, 0x0c, 0xf8, 0xe0, 0x36, 0xa5, 0xb2,
-	0x20, 0x6c, 0xa1, 0x22, 0x3e, 0x88, 0x0f, 0xc2, 0xd2, 0x37, 0x61, 0x99, 0xc5, 0xe7, 0x30, 0x35,
-	0xb7, 0x30, 0xd0, 0x4c, 0xb2, 0x73, 0x27, 0x6b, 0x29, 0xf8, 0x0d, 0x7e, 0x86, 0xbf, 0x29, 0x33,
-	0x77, 0x56, 0x83, 0x88, 0xf6, 0xed, 0x72, 0xee, 0xb9, 0x39, 0xe7, 0xcc, 0x21, 0x30, 0x5f, 0x2b,
-	0xa7, 0xae, 0x3b, 0xdb, 0x2a, 0xb7, 0xea, 0x6d, 0xe7, 0x3a, 0x01, 0x8d, 0x72, 0xea, 0x36, 0x20,
-	0x55, 0x0d, 0xc5, 0x5a, 0x93, 0xb3, 0xfa, 0x66, 0x70, 0xba, 0x33, 0x62, 0x0e, 0x69, 0xab, 0x4d,
-	0x99, 0x2c, 0x92, 0x65, 0x2a, 0xfd, 0x18, 0x10, 0x75, 0x5f, 0x3e, 0x89, 0x88, 0xba, 0x17, 0x02,
-	0xb2, 0x16, 0x95, 0x29, 0xd3, 0x45, 0xb2, 0x4c, 0x64, 0x98, 0xc5, 0x73, 0x38, 0x26, 0xd7, 0xd4,
-	0x0d, 0xee, 0xcb, 0x2c, 0xc0, 0x13, 0x72, 0xcd, 0x1a, 0xf7, 0xd5, 0x27, 0x48, 0xa5, 0xdc, 0x88,
-	0x33, 0x28, 0x2c, 0xee, 0x06, 0x24, 0x57, 0x93, 0x7e, 0xc0, 0x28, 0x90, 0x47, 0x6c, 0xab, 0x1f,
-	0x50, 0x9c, 0xc3, 0xcc, 0x22, 0xf5, 0x9d, 0x21, 0x64, 0x0e, 0x4b, 0x16, 0x07, 0xd0, 0x93, 0xaa,
-	0xd7, 0x90, 0x49, 0xb9, 0x21, 0x71, 0x0e, 0x99, 0xb5, 0x3d, 0x95, 0xc9, 0x22, 0x5d, 0xe6, 0x57,
-	0x4f, 0x57, 0xbf, 0x23, 0xad, 0xa4, 0xdc, 0xc8, 0xb0, 0xac, 0xbe, 0x41, 0x76, 0x7d, 0xd7, 0x7d,
-	0x15, 0x17, 0x70, 0x4a, 0x68, 0xf7, 0x68, 0x6b, 0xd5, 0x34, 0x16, 0x89, 0x82, 0x7c, 0x26, 0x67,
-	0x8c, 0x7e, 0x64, 0xd0, 0x67, 0x30, 0x43, 0x5b, 0x5b, 0xdb, 0x47, 0xe9, 0x89, 0x19, 0x5a, 0x69,
-	0x7b, 0xf1, 0x16, 0x4e, 0xb4, 0x71, 0x68, 0x6b, 0x8b, 0xbb, 0x90, 0x3a, 0xbf, 0x2a, 0xc7, 0x8a,
-	0xe3, 0x17, 0x94, 0xd3, 0x40, 0x95, 0xb8, 0xab, 0x2e, 0xe1, 0xc8, 0xcb, 0x93, 0x78, 0x05, 0x47,
-	0xb7, 0x7e, 0x88, 0x6e, 0xe7, 0xe3, 0x5b, 0xcf, 0x90, 0xbc, 0xae, 0x7e, 0x24, 0x70, 0xbc, 0x45,
-	0x22, 0x5f, 0xc4, 0x05, 0x9c, 0x7e, 0xb9, 0xd3, 0x68, 0xdc, 0x9f, 0x9e, 0x19, 0x3d, 0x78, 0x7e,
-	0x09, 0xb9, 0xf7, 0xcc, 0x41, 0x28, 0xfa, 0x06, 0x33, 0xb4, 0x5b, 0x46, 0xc4, 0x0b, 0x38, 0xf1,
-	0x04, 0xd6, 0x4f, 0xc3, 0x7a, 0x6a, 0x86, 0x96, 0x8d, 0xbd, 0x03, 0xe0, 0x60, 0x7e, 0x1d, 0x8a,
-	0xfb, 0x57, 0x32, 0x7e, 0x04, 0x7f, 0x59, 0xbd, 0x87, 0x69, 0x34, 0x4a, 0xe2, 0x12, 0xa6, 0x14,
-	0xe7, 0x18, 0xf0, 0xd9, 0xf8, 0x13, 0x91, 0x27, 0x7f, 0x91, 0xaa, 0xef, 0x09, 0x64, 0x9f, 0x09,
-	0xed, 0x63, 0x33, 0x9e, 0x41, 0xc1, 0x19, 0xa3, 0x08, 0x87, 0xcc, 0x43, 0xc8, 0xe8, 0xe1, 0x03,
-	0xcc, 0x38, 0x48, 0x24, 0xfd, 0xb7, 0xa5, 0x22, 0xd0, 0xe3, 0xbd, 0x6f, 0xca, 0x1b, 0x0a, 0x4d,
-	0x0d, 0x7e, 0xf8, 0x5b, 0x53, 0x9e, 0x21, 0x79, 0x7d, 0x33, 0x09, 0x7f, 0xd2, 0x9b, 0x9f, 0x01,
-	0x00, 0x00, 0xff, 0xff, 0xf2, 0xd7, 0x15, 0x53, 0x5d, 0x03, 0x00, 0x00,
+	// 481 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0xc1, 0x6a, 0xdb, 0x40,
+	0x10, 0x86, 0x51, 0x25, 0x3b, 0xf6, 0x48, 0x4e, 0x9c, 0xed, 0xa1, 0x82, 0x1e, 0xea, 0x28, 0xa4,
+	0x98, 0x14, 0x6c, 0x48, 0x29, 0x3d, 0x94, 0x1e, 0x0a, 0x26, 0xd0, 0x43, 0xc1, 0xac, 0xe9, 0x59,
+	0x28, 0xd5, 0x04, 0x04, 0xd1, 0x4a, 0xde, 0x59, 0xb9, 0x21, 0x6f, 0xd4, 0x67, 0xe8, 0x63, 0xf4,
+	0x85, 0xca, 0xee, 0xac, 0x13, 0x51, 0x0c, 0xe9, 0x6d, 0xf8, 0xe7, 0x87, 0x7f, 0xfe, 0x6f, 0x25,
+	0x98, 0xae, 0x0a, 0x53, 0x5c, 0x37, 0xba, 0x2e, 0xcc, 0xa2, 0xd5, 0x8d, 0x69, 0x04, 0x94, 0x85,
+	0x29, 0x6e, 0x9d, 0x92, 0xe5, 0x90, 0xac, 0x2a, 0x32, 0xba, 0xba, 0xe9, 0x4c, 0xd5, 0x28, 0x31,
+	0x85, 0xb0, 0xae, 0x54, 0x1a, 0xcc, 0x82, 0x79, 0x28, 0xed, 0xe8, 0x94, 0xe2, 0x3e, 0x7d, 0xe1,
+	0x95, 0xe2, 0x5e, 0x08, 0x88, 0x6a, 0x2c, 0x54, 0x1a, 0xce, 0x82, 0x79, 0x20, 0xdd, 0x2c, 0x5e,
+	0xc1, 0x11, 0x99, 0x32, 0x2f, 0x71, 0x97, 0x46, 0x4e, 0x1e, 0x92, 0x29, 0x57, 0xb8, 0xcb, 0xbe,
+	0x41, 0x28, 0xe5, 0x5a, 0x9c, 0x41, 0xa2, 0x71, 0xdb, 0x21, 0x99, 0x9c, 0xaa, 0x07, 0xf4, 0x01,
+	0xb1, 0xd7, 0x36, 0xd5, 0x03, 0x8a, 0x73, 0x98, 0x68, 0xa4, 0xb6, 0x51, 0x84, 0xec, 0xe1, 0xc8,
+	0x64, 0x2f, 0x5a, 0x53, 0xf6, 0x0e, 0x22, 0x29, 0xd7, 0x24, 0xce, 0x21, 0xd2, 0xba, 0xa5, 0x34,
+	0x98, 0x85, 0xf3, 0xf8, 0xea, 0x64, 0xf1, 0x54, 0x69, 0x21, 0xe5, 0x5a, 0xba, 0x65, 0xf6, 0x2b,
+	0x80, 0xe8, 0xfa, 0xae, 0xf9, 0x29, 0x2e, 0xe0, 0x98, 0x50, 0xef, 0x50, 0xe7, 0x45, 0x59, 0x6a,
+	0x24, 0x72, 0xf9, 0x91, 0x9c, 0xb0, 0xfa, 0x85, 0x45, 0x5b, 0x42, 0x75, 0x75, 0xae, 0x75, 0xeb,
+	0xb3, 0x87, 0xaa, 0xab, 0xa5, 0x6e, 0xc5, 0x07, 0x18, 0x57, 0xca, 0xa0, 0xce, 0x35, 0x6e, 0x5d,
+	0xed, 0xf8, 0x2a, 0xed, 0x47, 0xf6, 0x11, 0xca, 0x91, 0xb3, 0x4a, 0xdc, 0x8a, 0x4b, 0x38, 0xf5,
+	0xb1, 0x55, 0xfb, 0x98, 0x6c, 0xf1, 0x8c, 0xe5, 0x09, 0x2f, 0xbe, 0xb6, 0x3e, 0x3b, 0x5b, 0xc2,
+	0xc0, 0x9e, 0x4a, 0xe2, 0x2d, 0x0c, 0x6e, 0xed, 0xe0, 0xab, 0x4d, 0xfb, 0x39, 0xd6, 0x21, 0x79,
+	0x9d, 0xfd, 0x09, 0xe0, 0x68, 0x83, 0x44, 0xf6, 0xd5, 0x2e, 0xe0, 0xf8, 0xc7, 0x5d, 0x85, 0xca,
+	0xfc, 0xdb, 0x8f, 0xd5, 0x7d, 0xbf, 0x37, 0x10, 0xdb, 0x7e, 0x1c, 0x4d, 0xbe, 0x23, 0xa8, 0xae,
+	0xde, 0xb0, 0x22, 0x5e, 0xc3, 0xd8, 0x1a, 0x38, 0x3f, 0x74, 0xeb, 0x91, 0xea, 0x6a, 0x3e, 0xec,
+	0x23, 0x00, 0x43, 0xb0, 0xeb, 0x34, 0x7a, 0x86, 0x02, 0x03, 0x73, 0xf4, 0x2f, 0xe1, 0xd4, 0x5f,
+	0xd7, 0xc3, 0x30, 0x60, 0x0c, 0xbc, 0x78, 0xc2, 0xf0, 0x09, 0x46, 0xbe, 0x14, 0x89, 0x25, 0x8c,
+	0xc8, 0xcf, 0x1e, 0xc6, 0xcb, 0x7e, 0x9c, 0xf7, 0xc9, 0x47, 0x53, 0xf6, 0x3b, 0x80, 0xe8, 0x3b,
+	0xa1, 0xfe, 0x5f, 0x1e, 0x67, 0x90, 0x30, 0x0f, 0x1f, 0xc2, 0x40, 0x62, 0x07, 0xc4, 0xdf, 0xf0,
+	0x19, 0x26, 0x5c, 0xda, 0x9b, 0x9e, 0x7d, 0xfd, 0xc4, 0xd9, 0xf7, 0x0f, 0x73, 0xb0, 0x7a, 0x74,
+	0xb8, 0xfa, 0x12, 0x06, 0xf6, 0x78, 0xf7, 0x05, 0x74, 0x76, 0x38, 0xf4, 0x05, 0x58, 0x87, 0xe4,
+	0xf5, 0xcd, 0xd0, 0xfd, 0xce, 0xef, 0xff, 0x0e, 0x00, 0xb5, 0x23, 0xc8, 0xaf, 0xe2, 0x03, 0x00,
+	0x00,
 }
@@ -1,5 +1,7 @@
 package flows
 
+import "net"
+
 // TCPTimeout in Nanoseconds
 var TCPTimeout int64
 
@@ -12,18 +14,90 @@ var TCPFinTimeout int64
 // UDPTimeout in Nanoseconds
 var UDPTimeout int64
 
+// ICMPTimeout in Nanoseconds
+var ICMPTimeout int64
+
+// SCTPTimeout in Nanoseconds
+var SCTPTimeout int64
+
+// SCTPAbortTimeout in Nanoseconds
+var SCTPAbortTimeout int64
+
+// SCTPShutdownTimeout in Nanoseconds
+var SCTPShutdownTimeout int64
+
 // TCP Protocol
 const TCP uint8 = 1
 
 // UDP Protocol
 const UDP uint8 = 0
 
+// ICMP Protocol (ICMP over IPv4)
+const ICMP uint8 = 2
+
+// ICMPv6 Protocol
+const ICMPv6 uint8 = 3
+
+// SCTP Protocol
+const SCTP uint8 = 4
+
+// QUIC Protocol (UDP flows identified as QUIC connections)
+const QUIC uint8 = 5
+
+// QUICMaxConnIDLength is the maximal length of a QUIC connection ID (RFC 9000)
+const QUICMaxConnIDLength = 20
+
+// TunnelNone indicates that the packet was not tunneled
+const TunnelNone uint8 = 0
+
+// TunnelGRE Generic Routing Encapsulation, the tunnel ID is the GRE key (if present)
+const TunnelGRE uint8 = 1
+
+// TunnelVXLAN Virtual eXtensible LAN, the tunnel ID is the VNI
+const TunnelVXLAN uint8 = 2
+
+// TunnelGTPU GPRS Tunneling Protocol (user plane), the tunnel ID is the TEID
+const TunnelGTPU uint8 = 3
+
+// TunnelMPLS Multiprotocol Label Switching, the tunnel ID is the top label
+const TunnelMPLS uint8 = 4
+
+// TunnelIPIP IPv4/IPv6 encapsulated in IPv4/IPv6, no tunnel ID
+const TunnelIPIP uint8 = 5
+
 func GetProtocolString(protocol uint8) string {
 	switch protocol {
 	case TCP:
 		return "TCP"
 	case UDP:
 		return "UDP"
+	case ICMP:
+		return "ICMP"
+	case ICMPv6:
+		return "ICMPv6"
+	case SCTP:
+		return "SCTP"
+	case QUIC:
+		return "QUIC"
+	default:
+		return "Unknown"
+	}
+}
+
+func GetTunnelTypeString(tunnelType uint8) string {
+	switch tunnelType {
+	case TunnelNone:
+		return "None"
+	case TunnelGRE:
+		return "GRE"
+	case TunnelVXLAN:
+		return "VXLAN"
+	case TunnelGTPU:
+		return "GTP-U"
+	case TunnelMPLS:
+		return "MPLS"
+	case TunnelIPIP:
+		return "IPIP"
 	default:
 		return "Unknown"
 	}
@@ -34,56 +108,157 @@ func GetProtocolString(protocol uint8) string {
 // Used for flow construction (basically a hash: uint64)
 type FlowKeyType uint64
 
+// IPAddress stores an IPv4 or IPv6 address without allocating.
+// IPv4 addresses are stored as IPv4-mapped IPv6 addresses (::ffff:a.b.c.d).
+type IPAddress [net.IPv6len]byte
+
+// NewIPAddress converts a 4 or 16 byte address (e.g. as decoded by gopacket) to an IPAddress
+func NewIPAddress(ip []byte) (address IPAddress) {
+	if len(ip) == net.IPv4len {
+		address[10] = 0xff
+		address[11] = 0xff
+		copy(address[12:], ip)
+	} else {
+		copy(address[:], ip)
+	}
+	return address
+}
+
+// String returns the address in dotted decimal (IPv4) or RFC 5952 (IPv6) notation
+func (address IPAddress) String() string {
+	return net.IP(address[:]).String()
+}
+
+// Bytes returns the address as 4 byte slice for IPv4 and as 16 byte slice for IPv6
+func (address IPAddress) Bytes() []byte {
+	ip := net.IP(address[:])
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4
+	}
+	return ip
+}
+
 type PacketInformation struct {
-	PacketIdx     int64
-	FlowKey       FlowKeyType
-	SrcPort       uint16
-	DstPort       uint16
-	PayloadLength uint16
-	TCPAckNr      uint32
-	TCPSeqNr      uint32
-	SrcIP         uint64
-	DstIP         uint64
-	Timestamp     int64
-	TCPFIN        bool
-	TCPACK        bool
-	TCPRST        bool
-	TCPSYN        bool
-	HasTCP        bool
-	HasUDP        bool
+	PacketIdx      int64
+	FlowKey        FlowKeyType
+	SrcPort        uint16
+	DstPort        uint16
+	PayloadLength  uint32 // uint32, since IPv6 jumbograms can exceed 65535 bytes
+	TCPAckNr       uint32
+	TCPSeqNr       uint32
+	SrcIP          uint64 // Hash of SrcIPAddress, used to compute the FlowKey
+	DstIP          uint64 // Hash of DstIPAddress, used to compute the FlowKey
+	Timestamp      int64
+	SrcIPAddress   IPAddress
+	DstIPAddress   IPAddress
+	QUIC           *QUICHeader // Only set between parser and connection ID tracking
+	Fragment       *IPFragment // Only set between parser and fragment reassembly
+	TunnelID       uint32      // ID of the outermost tunnel (VNI, TEID, label, GRE key)
+	TunnelType     uint8       // Type of the outermost tunnel, TunnelNone if the packet was not tunneled
+	Interface      uint32      // Capture interface (pcapng interface ID)
+	ICMPIdentifier uint16
+	TCPWindow      uint16     // Receive window as advertised in the header (not scaled)
+	TCPOptions     TCPOptions // Only set for SYN packets
+	ICMPType       uint8      // Type of the request, reply types are mapped to the type of their request
+	ICMPCode       uint8
+	ICMPReply      bool
+	ICMPv6         bool
+	TCPFIN         bool
+	TCPACK         bool
+	TCPRST         bool
+	TCPSYN         bool
+	SCTPINIT       bool
+	SCTPINITACK    bool
+	SCTPABORT      bool
+	SCTPSHUTDOWN   bool // Set for SHUTDOWN, SHUTDOWN ACK and SHUTDOWN COMPLETE chunks
+	HasTCP         bool
+	HasUDP         bool
+	HasICMP        bool
+	HasSCTP        bool
+	HasQUIC        bool // UDP packet carrying a QUIC header, HasUDP is set as well
+}
+
+// IPFragment contains a fragment of an IPv4 or IPv6 datagram.
+// The transport header can only be decoded after all fragments are reassembled.
+type IPFragment struct {
+	Data           []byte // Fragment data, can be shorter than Length if the packet was truncated during capture
+	Offset         uint32 // In bytes
+	Length         uint32 // Length of the fragment according to the IP header
+	Identification uint32
+	Protocol       uint8 // Upper layer protocol
+	MoreFragments  bool
+	IPv6           bool
+}
+
+// QUICHeader contains the connection IDs of a QUIC packet.
+// The length of the destination connection ID of a short header is not part of the header.
+// Therefore all available bytes (up to QUICMaxConnIDLength) are stored and DstConnIDLength is the number of stored bytes.
+type QUICHeader struct {
+	DstConnID       [QUICMaxConnIDLength]byte
+	SrcConnID       [QUICMaxConnIDLength]byte
+	DstConnIDLength uint8
+	SrcConnIDLength uint8
+	LongHeader      bool
 }
 
-// Packet defines a TCP or UDP Packet
+// Packet defines a TCP, UDP, ICMP or SCTP Packet
 // Take care of field order to ensure no wasted memory due to memalign
 type Packet struct {
 	Timestamp     int64
 	PacketIdx     int64
-	LengthPayload uint16
+	LengthPayload uint32
 	FromClient    bool
 }
 
 type TCPPacket struct {
-	SeqNr uint32
-	AckNr uint32
-	ACK   bool
-	SYN   bool
-	RST   bool
-	FIN   bool
+	SeqNr  uint32
+	AckNr  uint32
+	Window uint16 // Not scaled
+	ACK    bool
+	SYN    bool
+	RST    bool
+	FIN    bool
+}
+
+// TCPOptions contains the options of a SYN packet, which are negotiated during the handshake
+type TCPOptions struct {
+	MSS            uint16 // 0 if not present
+	WindowScale    uint8  // Shift count, only valid if HasWindowScale is set
+	HasWindowScale bool
+	SACKPermitted  bool
+	Timestamps     bool
+}
+
+// TCPMaxWindowScale is the maximal shift count of the window scale option, larger values are treated as 14 (RFC 7323)
+const TCPMaxWindowScale = 14
+
+// SCTPPacket contains the chunk types relevant for the association state.
+// A single SCTP packet can bundle several chunks.
+type SCTPPacket struct {
+	INIT     bool
+	INITACK  bool
+	ABORT    bool
+	SHUTDOWN bool
 }
 
 // Flow is a connection between two application instances.
 type Flow struct {
 	// The client is the one who initiates the connection or based on lower port number
 	// In case no SYN packets are processed, client is the one who sends the first packet
-	FlowKey      FlowKeyType
-	Timeout      int64
-	ClusterIndex int
-	ClientAddr   uint64
-	ServerAddr   uint64
-	ClientPort   uint16
-	ServerPort   uint16
-	Protocol     uint8 // Indicates transport protocol (TCP/UDP)
-	Packets      []Packet
+	FlowKey         FlowKeyType
+	Timeout         int64
+	ClusterIndex    int
+	ClientAddr      uint64 // Hash of ClientIPAddress
+	ServerAddr      uint64 // Hash of ServerIPAddress
+	ClientIPAddress IPAddress
+	ServerIPAddress IPAddress
+	ClientPort      uint16
+	ServerPort      uint16
+	Protocol        uint8 // Indicates transport protocol (TCP/UDP/ICMP/ICMPv6/SCTP/QUIC)
+	TunnelType      uint8 // Outermost tunnel, part of the flow key. The flow is built from the innermost headers
+	TunnelID        uint32
+	Interface       uint32 // Capture interface of the first packet
+	Packets         []Packet
 }
 
 // TCPFlow is a Flow with special fields for TCP connections
@@ -92,19 +267,46 @@ type TCPFlow struct {
 	TCPPacket     []TCPPacket
 	RSTIndex      int32
 	FirstFINIndex int32
+	// Options of the last SYN sent by the client and the server, only valid if ClientSYN/ServerSYN is set
+	ClientOptions TCPOptions
+	ServerOptions TCPOptions
+	ClientSYN     bool
+	ServerSYN     bool
 }
 
-// UDPFlow is a Flow with special fields for UDP connections
+// UDPFlow is a Flow with special fields for UDP connections.
+// QUIC connections are UDPFlows with protocol QUIC, which are identified by their connection ID instead of the 5-tuple.
 type UDPFlow struct {
 	Flow
 }
 
+// SCTPFlow is a Flow with special fields for SCTP associations
+type SCTPFlow struct {
+	Flow
+	SCTPPacket         []SCTPPacket
+	AbortIndex         int32
+	FirstShutdownIndex int32
+}
+
+// ICMPFlow is a Flow with special fields for ICMP and ICMPv6 messages.
+// Client and server port are both set to the ICMP type of the request (e.g. 8 for echo request),
+// so echo requests and replies belong to the same flow and protocol (ICMP_8).
+type ICMPFlow struct {
+	Flow
+	Type       uint8
+	Code       uint8
+	Identifier uint16
+}
+
 // NewTCPFlow creates a new TCP Flow with default values
 func NewTCPFlow(packetInfo PacketInformation) *TCPFlow {
 	f := TCPFlow{
 		Flow: Flow{
-			Protocol: TCP,
-			FlowKey:  packetInfo.FlowKey,
+			Protocol:   TCP,
+			FlowKey:    packetInfo.FlowKey,
+			TunnelType: packetInfo.TunnelType,
+			TunnelID:   packetInfo.TunnelID,
+			Interface:  packetInfo.Interface,
 		},
 		FirstFINIndex: -1,
 		RSTIndex:      -1,
@@ -120,10 +322,56 @@ func NewTCPFlow(packetInfo PacketInformation) *TCPFlow {
 func NewUDPFlow(packetInfo PacketInformation) *UDPFlow {
 	f := UDPFlow{
 		Flow: Flow{
-			Protocol: UDP,
-			FlowKey:  packetInfo.FlowKey,
+			Protocol:   UDP,
+			FlowKey:    packetInfo.FlowKey,
+			TunnelType: packetInfo.TunnelType,
+			TunnelID:   packetInfo.TunnelID,
+			Interface:  packetInfo.Interface,
 		},
 	}
+	if packetInfo.HasQUIC {
+		f.Protocol = QUIC
+	}
+	f.setClientServer(packetInfo)
+	f.AddPacket(packetInfo)
+	return &f
+}
+
+// NewSCTPFlow creates a new SCTP Flow with default values
+func NewSCTPFlow(packetInfo PacketInformation) *SCTPFlow {
+	f := SCTPFlow{
+		Flow: Flow{
+			Protocol:   SCTP,
+			FlowKey:    packetInfo.FlowKey,
+			TunnelType: packetInfo.TunnelType,
+			TunnelID:   packetInfo.TunnelID,
+			Interface:  packetInfo.Interface,
+		},
+		AbortIndex:         -1,
+		FirstShutdownIndex: -1,
+	}
+	f.setClientServer(packetInfo)
+	f.AddPacket(packetInfo)
+	return &f
+}
+
+// NewICMPFlow creates a new ICMP Flow with default values
+func NewICMPFlow(packetInfo PacketInformation) *ICMPFlow {
+	f := ICMPFlow{
+		Flow: Flow{
+			Protocol:   ICMP,
+			FlowKey:    packetInfo.FlowKey,
+			TunnelType: packetInfo.TunnelType,
+			TunnelID:   packetInfo.TunnelID,
+			Interface:  packetInfo.Interface,
+		},
+		Type:       packetInfo.ICMPType,
+		Code:       packetInfo.ICMPCode,
+		Identifier: packetInfo.ICMPIdentifier,
+	}
+	if packetInfo.ICMPv6 {
+		f.Protocol = ICMPv6
+	}
 	f.setClientServer(packetInfo)
 	f.AddPacket(packetInfo)
 	return &f
@@ -131,7 +379,8 @@ func NewUDPFlow(packetInfo PacketInformation) *UDPFlow {
 
 func (f *Flow) addPacket(packetInfo PacketInformation) {
 	var newPacket = Packet{
-		FromClient:    f.ClientAddr == packetInfo.SrcIP && f.ClientPort == packetInfo.SrcPort,
+		// Compare with the server, since the client address of a QUIC connection can change (connection migration)
+		FromClient:    f.ServerAddr != packetInfo.SrcIP || f.ServerPort != packetInfo.SrcPort,
 		PacketIdx:     packetInfo.PacketIdx,
 		Timestamp:     packetInfo.Timestamp,
 		LengthPayload: packetInfo.PayloadLength}
@@ -142,12 +391,22 @@ func (f *Flow) addPacket(packetInfo PacketInformation) {
 func (f *TCPFlow) AddPacket(packetInfo PacketInformation) {
 	f.Flow.addPacket(packetInfo) // super method
 	f.TCPPacket = append(f.TCPPacket, TCPPacket{
-		SeqNr: packetInfo.TCPSeqNr,
-		AckNr: packetInfo.TCPAckNr,
-		ACK:   packetInfo.TCPACK,
-		FIN:   packetInfo.TCPFIN,
-		RST:   packetInfo.TCPRST,
-		SYN:   packetInfo.TCPSYN})
+		SeqNr:  packetInfo.TCPSeqNr,
+		AckNr:  packetInfo.TCPAckNr,
+		Window: packetInfo.TCPWindow,
+		ACK:    packetInfo.TCPACK,
+		FIN:    packetInfo.TCPFIN,
+		RST:    packetInfo.TCPRST,
+		SYN:    packetInfo.TCPSYN})
+	if packetInfo.TCPSYN {
+		if f.Packets[len(f.Packets)-1].FromClient {
+			f.ClientOptions = packetInfo.TCPOptions
+			f.ClientSYN = true
+		} else {
+			f.ServerOptions = packetInfo.TCPOptions
+			f.ServerSYN = true
+		}
+	}
 	switch {
 	case packetInfo.TCPRST:
 		f.RSTIndex = int32(len(f.Packets) - 1)
@@ -160,6 +419,37 @@ func (f *TCPFlow) AddPacket(packetInfo PacketInformation) {
 	}
 }
 
+// WindowScale returns the shift counts of the windows advertised by the client and the server.
+// Window scaling is only used, if both sent the option in their SYN (RFC 7323).
+// If the handshake was not observed, the windows are assumed to be unscaled.
+func (f *TCPFlow) WindowScale() (client, server uint8) {
+	if !f.ClientSYN || !f.ServerSYN || !f.ClientOptions.HasWindowScale || !f.ServerOptions.HasWindowScale {
+		return 0, 0
+	}
+	client, server = f.ClientOptions.WindowScale, f.ServerOptions.WindowScale
+	if client > TCPMaxWindowScale {
+		client = TCPMaxWindowScale
+	}
+	if server > TCPMaxWindowScale {
+		server = TCPMaxWindowScale
+	}
+	return client, server
+}
+
+// ScaledWindow returns the receive window in bytes advertised by the packet with the index.
+// The window of SYN packets is never scaled.
+func (f *TCPFlow) ScaledWindow(index int) uint32 {
+	tcpPacket := f.TCPPacket[index]
+	if tcpPacket.SYN {
+		return uint32(tcpPacket.Window)
+	}
+	client, server := f.WindowScale()
+	if f.Packets[index].FromClient {
+		return uint32(tcpPacket.Window) << client
+	}
+	return uint32(tcpPacket.Window) << server
+}
+
 func (f *TCPFlow) setClientServer(packetInfo PacketInformation) {
 	switch {
 	case packetInfo.TCPSYN && !packetInfo.TCPACK:
@@ -168,24 +458,32 @@ func (f *TCPFlow) setClientServer(packetInfo PacketInformation) {
 		f.ClientPort = packetInfo.SrcPort
 		f.ServerAddr = packetInfo.DstIP
 		f.ServerPort = packetInfo.DstPort
+		f.ClientIPAddress = packetInfo.SrcIPAddress
+		f.ServerIPAddress = packetInfo.DstIPAddress
 	case packetInfo.TCPSYN && packetInfo.TCPACK:
 		// From Server
 		f.ClientAddr = packetInfo.DstIP
 		f.ClientPort = packetInfo.DstPort
 		f.ServerAddr = packetInfo.SrcIP
 		f.ServerPort = packetInfo.SrcPort
+		f.ClientIPAddress = packetInfo.DstIPAddress
+		f.ServerIPAddress = packetInfo.SrcIPAddress
 	case packetInfo.SrcPort <= 49151 && packetInfo.SrcPort < packetInfo.DstPort:
 		// From Server
 		f.ClientAddr = packetInfo.DstIP
 		f.ClientPort = packetInfo.DstPort
 		f.ServerAddr = packetInfo.SrcIP
 		f.ServerPort = packetInfo.SrcPort
+		f.ClientIPAddress = packetInfo.DstIPAddress
+		f.ServerIPAddress = packetInfo.SrcIPAddress
 	default:
 		// From Client
 		f.ClientAddr = packetInfo.SrcIP
 		f.ClientPort = packetInfo.SrcPort
 		f.ServerAddr = packetInfo.DstIP
 		f.ServerPort = packetInfo.DstPort
+		f.ClientIPAddress = packetInfo.SrcIPAddress
+		f.ServerIPAddress = packetInfo.DstIPAddress
 	}
 }
 
@@ -202,11 +500,100 @@ func (f *UDPFlow) setClientServer(packetInfo PacketInformation) {
 		f.ClientPort = packetInfo.DstPort
 		f.ServerAddr = packetInfo.SrcIP
 		f.ServerPort = packetInfo.SrcPort
+		f.ClientIPAddress = packetInfo.DstIPAddress
+		f.ServerIPAddress = packetInfo.SrcIPAddress
 	} else {
 		// From Client
 		f.ClientAddr = packetInfo.SrcIP
 		f.ClientPort = packetInfo.SrcPort
 		f.ServerAddr = packetInfo.DstIP
 		f.ServerPort = packetInfo.DstPort
+		f.ClientIPAddress = packetInfo.SrcIPAddress
+		f.ServerIPAddress = packetInfo.DstIPAddress
+	}
+}
+
+// AddPacket to ICMP Flow
+func (f *ICMPFlow) AddPacket(packetInfo PacketInformation) {
+	f.Flow.addPacket(packetInfo) // super method
+	f.Timeout = packetInfo.Timestamp + ICMPTimeout
+}
+
+func (f *ICMPFlow) setClientServer(packetInfo PacketInformation) {
+	if packetInfo.ICMPReply {
+		// From Server
+		f.ClientAddr = packetInfo.DstIP
+		f.ClientPort = packetInfo.DstPort
+		f.ServerAddr = packetInfo.SrcIP
+		f.ServerPort = packetInfo.SrcPort
+		f.ClientIPAddress = packetInfo.DstIPAddress
+		f.ServerIPAddress = packetInfo.SrcIPAddress
+	} else {
+		// From Client
+		f.ClientAddr = packetInfo.SrcIP
+		f.ClientPort = packetInfo.SrcPort
+		f.ServerAddr = packetInfo.DstIP
+		f.ServerPort = packetInfo.DstPort
+		f.ClientIPAddress = packetInfo.SrcIPAddress
+		f.ServerIPAddress = packetInfo.DstIPAddress
+	}
+}
+
+// AddPacket to SCTP Flow
+func (f *SCTPFlow) AddPacket(packetInfo PacketInformation) {
+	f.Flow.addPacket(packetInfo) // super method
+	f.SCTPPacket = append(f.SCTPPacket, SCTPPacket{
+		INIT:     packetInfo.SCTPINIT,
+		INITACK:  packetInfo.SCTPINITACK,
+		ABORT:    packetInfo.SCTPABORT,
+		SHUTDOWN: packetInfo.SCTPSHUTDOWN})
+	switch {
+	case packetInfo.SCTPABORT:
+		f.AbortIndex = int32(len(f.Packets) - 1)
+		f.Timeout = packetInfo.Timestamp + SCTPAbortTimeout
+	case packetInfo.SCTPSHUTDOWN && f.FirstShutdownIndex == -1:
+		f.FirstShutdownIndex = int32(len(f.Packets) - 1)
+		f.Timeout = packetInfo.Timestamp + SCTPShutdownTimeout
+	case f.FirstShutdownIndex != -1:
+		// Do not extend the timeout of an association which is shutting down
+	default:
+		f.Timeout = packetInfo.Timestamp + SCTPTimeout
+	}
+}
+
+func (f *SCTPFlow) setClientServer(packetInfo PacketInformation) {
+	switch {
+	case packetInfo.SCTPINIT:
+		// From Client
+		f.ClientAddr = packetInfo.SrcIP
+		f.ClientPort = packetInfo.SrcPort
+		f.ServerAddr = packetInfo.DstIP
+		f.ServerPort = packetInfo.DstPort
+		f.ClientIPAddress = packetInfo.SrcIPAddress
+		f.ServerIPAddress = packetInfo.DstIPAddress
+	case packetInfo.SCTPINITACK:
+		// From Server
+		f.ClientAddr = packetInfo.DstIP
+		f.ClientPort = packetInfo.DstPort
+		f.ServerAddr = packetInfo.SrcIP
+		f.ServerPort = packetInfo.SrcPort
+		f.ClientIPAddress = packetInfo.DstIPAddress
+		f.ServerIPAddress = packetInfo.SrcIPAddress
+	case packetInfo.SrcPort <= 49151 && packetInfo.SrcPort < packetInfo.DstPort:
+		// From Server
+		f.ClientAddr = packetInfo.DstIP
+		f.ClientPort = packetInfo.DstPort
+		f.ServerAddr = packetInfo.SrcIP
+		f.ServerPort = packetInfo.SrcPort
+		f.ClientIPAddress = packetInfo.DstIPAddress
+		f.ServerIPAddress = packetInfo.SrcIPAddress
+	default:
+		// From Client
+		f.ClientAddr = packetInfo.SrcIP
+		f.ClientPort = packetInfo.SrcPort
+		f.ServerAddr = packetInfo.DstIP
+		f.ServerPort = packetInfo.DstPort
+		f.ClientIPAddress = packetInfo.SrcIPAddress
+		f.ServerIPAddress = packetInfo.DstIPAddress
 	}
 }
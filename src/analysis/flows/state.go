@@ -0,0 +1,113 @@
+package flows
+
+// This file contains the classification of TCP connections by the flags of their packets.
+// The states follow the conn_state field of Zeek's conn.log. The originator is the client.
+
+import (
+	"strings"
+)
+
+// TCPConnectionState is the state of a TCP connection at the time it is flushed
+type TCPConnectionState uint8
+
+const (
+	TCPStateS0     TCPConnectionState = iota // SYN seen, no reply
+	TCPStateS1                               // Established, not terminated
+	TCPStateSF                               // Established and terminated by both sides
+	TCPStateREJ                              // SYN answered by RST
+	TCPStateS2                               // Established, termination by the client without reply
+	TCPStateS3                               // Established, termination by the server without reply
+	TCPStateRSTO                             // Established, aborted by the client
+	TCPStateRSTR                             // Established, aborted by the server
+	TCPStateRSTOS0                           // SYN followed by RST of the client, no SYN/ACK seen
+	TCPStateRSTRH                            // SYN/ACK followed by RST of the server, no SYN seen
+	TCPStateSH                               // SYN followed by FIN of the client, no SYN/ACK seen
+	TCPStateSHR                              // SYN/ACK followed by FIN of the server, no SYN seen
+	TCPStateOTH                              // No SYN seen, midstream traffic
+	NumTCPConnectionStates
+)
+
+var tcpConnectionStateNames = [NumTCPConnectionStates]string{
+	"S0", "S1", "SF", "REJ", "S2", "S3", "RSTO", "RSTR", "RSTOS0", "RSTRH", "SH", "SHR", "OTH",
+}
+
+// String returns the Zeek name of the state
+func (state TCPConnectionState) String() string {
+	if state >= NumTCPConnectionStates {
+		return "UNKNOWN"
+	}
+	return tcpConnectionStateNames[state]
+}
+
+// ParseTCPConnectionState returns the state with the (case insensitive) name
+func ParseTCPConnectionState(name string) (TCPConnectionState, bool) {
+	for state, stateName := range tcpConnectionStateNames {
+		if strings.EqualFold(name, stateName) {
+			return TCPConnectionState(state), true
+		}
+	}
+	return 0, false
+}
+
+// ConnectionState classifies the connection by the SYN, FIN and RST flags of both directions
+func (f *TCPFlow) ConnectionState() TCPConnectionState {
+	var syn, synAck, finClient, finServer bool
+	var rstClient, rstServer bool
+	for i, packet := range f.Packets {
+		tcpPacket := f.TCPPacket[i]
+		switch {
+		case tcpPacket.RST:
+			// Only the first RST is relevant
+			if !rstClient && !rstServer {
+				rstClient = packet.FromClient
+				rstServer = !packet.FromClient
+			}
+		case tcpPacket.SYN && !tcpPacket.ACK && packet.FromClient:
+			syn = true
+		case tcpPacket.SYN && tcpPacket.ACK && !packet.FromClient:
+			synAck = true
+		}
+		if tcpPacket.FIN {
+			if packet.FromClient {
+				finClient = true
+			} else {
+				finServer = true
+			}
+		}
+	}
+
+	switch {
+	case syn && synAck:
+		switch {
+		case rstClient:
+			return TCPStateRSTO
+		case rstServer:
+			return TCPStateRSTR
+		case finClient && finServer:
+			return TCPStateSF
+		case finClient:
+			return TCPStateS2
+		case finServer:
+			return TCPStateS3
+		}
+		return TCPStateS1
+	case syn:
+		switch {
+		case rstServer:
+			return TCPStateREJ
+		case rstClient:
+			return TCPStateRSTOS0
+		case finClient:
+			return TCPStateSH
+		}
+		return TCPStateS0
+	case synAck:
+		switch {
+		case rstServer:
+			return TCPStateRSTRH
+		case finServer:
+			return TCPStateSHR
+		}
+	}
+	return TCPStateOTH
+}
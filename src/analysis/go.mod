@@ -7,14 +7,15 @@ require (
 	github.com/dustin/go-humanize v1.0.1
 	github.com/golang/protobuf v1.5.4
 	github.com/google/gopacket v1.1.19
+	github.com/klauspost/compress v1.17.9
 	github.com/klauspost/pgzip v1.2.6
+	github.com/ulikunitz/xz v0.5.12
 	github.com/uncatchable-de/goml v0.0.0-20190809191221-70531a547d49
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
 )
 
 require (
 	github.com/Fabse333/goml v0.0.0-20190809191221-70531a547d49 // indirect
-	github.com/klauspost/compress v1.17.9 // indirect
-	golang.org/x/net v0.0.0-20190620200207-3b0461eec859 // indirect
 	golang.org/x/sys v0.0.0-20190412213103-97732733099d // indirect
 	google.golang.org/protobuf v1.33.0 // indirect
 )
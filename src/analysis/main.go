@@ -2,6 +2,7 @@ package main
 
 import (
 	"scalable-flow-analyzer/flows"
+	"scalable-flow-analyzer/metrics/common"
 	flowMetrics "scalable-flow-analyzer/metrics/flows"
 	standardMetrics "scalable-flow-analyzer/metrics/standard"
 	"scalable-flow-analyzer/parser"
@@ -13,11 +14,15 @@ import (
 
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path"
 	"runtime"
 	"runtime/pprof"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -33,7 +38,6 @@ const numParserChannel = 8
 
 // Flush every x seconds (relative to packet timestamps, not processing time)
 const flushRate = int64(40 * time.Second)
-const packetStop = 10000 * million
 
 // The two different kinds of metrics one can choose by using the 'flow' flag
 var standardMetric *standardMetrics.Metric
@@ -43,31 +47,66 @@ var defaultTCPTimeout, _ = time.ParseDuration("5m0s")
 var defaultTCPFinTimeout, _ = time.ParseDuration("2s")
 var defaultTCPRstTimeout, _ = time.ParseDuration("1s")
 var defaultUDPTimeout, _ = time.ParseDuration("5m0s")
+var defaultSCTPTimeout, _ = time.ParseDuration("5m0s")
+var defaultSCTPAbortTimeout, _ = time.ParseDuration("1s")
+var defaultSCTPShutdownTimeout, _ = time.ParseDuration("2s")
+var defaultICMPTimeout, _ = time.ParseDuration("30s")
 var defaultSessionTimeout, _ = time.ParseDuration("10m")
+var defaultFragmentTimeout, _ = time.ParseDuration("30s")
 
-var input = flag.String("i", "", "Path to .pcapng or .pcapng.gz files or to directory with these files (not in combination with --interface)")
+var input = flag.String("i", "", "Path to a pcap/pcapng file (optionally compressed with gzip, bzip2, zstd or xz), a named pipe, a directory with these files or - for stdin (not in combination with --interface)")
+var mergeInput = flag.Bool("merge", false, "If set, all input files are opened at once and their packets are merged by timestamp (e.g. separate uplink and downlink captures). Otherwise the files are read one after another.")
+var bpfFilter = flag.String("bpf", "", "BPF filter expression (pcap-filter syntax) e.g. 'tcp port 443 or udp'. Applied in the kernel for live captures and to every packet read from files. Packets of link types the filter cannot be compiled for (e.g. Linux cooked v2) are not filtered.")
+var captureInterfaces = flag.String("captureInterfaces", "", "Only analyze packets captured on these pcapng interfaces, given by interface ID or name e.g. 0,eth1. With -merge, the interface IDs are numbered across all files in order of their first packet. (Default: all interfaces)")
 var interfaceName = flag.String("interface", "", "Interface name to capture packets from (not in combination with -i)")
+var captureBackend = flag.String("captureBackend", "pcap", "Backend for live captures: 'pcap' (libpcap) or 'afpacket' (Linux AF_PACKET socket with TPACKET_V3 ring for high packet rates)")
+var afpacketBlockSize = flag.Int("afpacketBlockSize", 1024*1024, "Size of a block of the afpacket ring in bytes (multiple of the page size)")
+var afpacketNumBlocks = flag.Int("afpacketNumBlocks", 128, "Number of blocks of the afpacket ring")
+var fanoutGroup = flag.Int("fanoutGroup", -1, "afpacket fanout group ID (0-65535). The kernel distributes the packets between all analyzer processes in the same group. (Default: -1 (no fanout))")
+var fanoutMode = flag.String("fanoutMode", "hash", "afpacket fanout mode: hash (both directions of a flow go to the same process), lb, cpu, rollover, random or qm")
+var maxPackets = flag.Int64("maxPackets", 0, "Stop after this number of packets (after -captureInterfaces and -bpf filtering). (Default: 0 (unlimited))")
+var maxBytes = flag.Int64("maxBytes", 0, "Stop after this number of bytes (packet lengths on the wire). (Default: 0 (unlimited))")
+var maxDuration = flag.Duration("maxDuration", 0, "Stop after this duration e.g. 10m. The clock is defined by -durationClock. (Default: 0 (unlimited))")
+var durationClock = flag.String("durationClock", "trace", "Clock of -maxDuration: 'trace' (packet timestamps relative to the first packet, reproducible for files) or 'wall' (time since the start of reading)")
 var exportDirectory = flag.String("export", "", "Export directory to store the metrics files (Default: metrics)")
+var rotateInterval = flag.Duration("rotate", 0, "Export the metrics every interval e.g. 1h to a subdirectory of the export directory named by the interval start (UTC) and reset them. The flow metrics file rolls over at the same time. Intervals are based on packet timestamps. (Default: 0 (no rotation))")
 var computeFlowMetrics = flag.Bool("flow", true, "Compute flow metrics instead of default metrics (Default: true)")
 var tcpFilter = flag.String("tcpFilter", "0-65535", "Filter TCP ports e.g. 0-1023,8080,8443")
-var tcpDropIncomplete = flag.Bool("tcpDropIncomplete", false, "If set, the analyzer drops all tcp flows without a SYN packet.")
+var flowFilter = flag.String("flowFilter", "", "Only export flows matching this expression e.g. 'tcp and server.port in (443,8443) and bytes > 10k'. Supports and/or/not, the keywords tcp, udp, quic, sctp, icmp, icmpv6, complete, closed, bidirectional, unidirectional and comparisons of [client.|server.]ip (subnets), [client.|server.]port, [client.|server.]packets, [client.|server.]bytes, duration, interface and tunnel.id, as well as the connection state of TCP flows e.g. 'state in (SF, S1)'.")
+var tcpDropIncomplete = flag.Bool("tcpDropIncomplete", false, "If set, the analyzer drops all tcp flows without a SYN packet. Use the connection state of -flowFilter (e.g. 'state in (SF, S1)') for a finer selection.")
 var dropUnidirectional = flag.Bool("dropUnidirectional", false, "If set, the analyzer will drop all unidirectional traffic. Note, that the reconstruction of TCP flows happens first (if tcpReconstructResponse argument is set).")
 var tcpReconstructResponse = flag.Bool("tcpReconstructResponse", false, "If set, the analyzer will try to reconstruct all unidirectional TCP flows, for which only the the packets from the client to the server were captured.")
+var tcpExcludeRetransmissions = flag.Bool("tcpExcludeRetransmissions", false, "If set, retransmitted TCP payload is not counted in the request and response sizes.")
+var tcpSegmentBySequence = flag.Bool("tcpSegmentBySequence", false, "If set, the payload of TCP flows is ordered and de-duplicated by sequence number before requests and responses are identified. Otherwise, requests and responses are split on direction changes in capture order.")
 var udpFilter = flag.String("udpFilter", "0-65535", "Filter UDP ports e.g. 0-1023,8080,8443")
+var quicPorts = flag.String("quicPorts", "443", "UDP ports on which QUIC is detected e.g. 443,8443. QUIC flows are identified by connection ID and exported as QUIC_<port>. Empty string disables QUIC detection.")
+var decapsulate = flag.String("decapsulate", "gre,ipip", "Tunnel protocols which are decapsulated e.g. gre,vxlan,gtpu,mpls,ipip. Flows are built from the innermost IP header and separated by the outermost tunnel (type and ID), which is recorded.")
 var tcpTimeout = flag.Duration("tcpTimeout", defaultTCPTimeout, "TCP timeout after idle time period")
 var tcpFinTimeout = flag.Duration("tcpFinTimeout", defaultTCPFinTimeout, "TCP timeout after a FIN is received")
 var tcpRstTimeout = flag.Duration("tcpRstTimeout", defaultTCPRstTimeout, "TCP timeout after a RST is received")
 var udpTimeout = flag.Duration("udpTimeout", defaultUDPTimeout, "UDP timeout after idle time period")
+var sctpTimeout = flag.Duration("sctpTimeout", defaultSCTPTimeout, "SCTP timeout after idle time period")
+var sctpAbortTimeout = flag.Duration("sctpAbortTimeout", defaultSCTPAbortTimeout, "SCTP timeout after an ABORT is received")
+var sctpShutdownTimeout = flag.Duration("sctpShutdownTimeout", defaultSCTPShutdownTimeout, "SCTP timeout after a SHUTDOWN is received")
+var icmpTimeout = flag.Duration("icmpTimeout", defaultICMPTimeout, "ICMP/ICMPv6 timeout after idle time period")
 var sessionTimeout = flag.Duration("sessionTimeout", defaultSessionTimeout, "Session timeout after idle time period")
+var reassembleFragments = flag.Bool("reassembleFragments", false, "If set, the analyzer reassembles fragmented IPv4 and IPv6 packets. Otherwise fragments (including first fragments) are read and counted like any other packet, but their transport header is not decoded, so they are not added to any flow.")
+var fragmentTimeout = flag.Duration("fragmentTimeout", defaultFragmentTimeout, "Time after the first fragment until an incomplete datagram is dropped")
+var fragmentMemory = flag.Int("fragmentMemory", 64*1024*1024, "Maximum number of bytes buffered for fragment reassembly. If exceeded, the oldest incomplete datagrams are dropped.")
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to `file`")
 var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
 var blockprofile = flag.String("blockprofile", "", "write block profile to `file`")
 var samplingrate = flag.Float64("sampling", 100, "Sampling rate in percent")
-var samplingrateFlows = flag.Int64("samplingFlows", 0, "Sampling rate for flow rate metric in ms. (Default: 0 (average over entire flow))")
+var samplingrateFlows = flag.Int64("samplingFlows", 0, "Sampling rate for flow rate metric and TCP window evolution in ms. (Default: 0 (average over entire flow, every window change))")
 var infoDirectory = flag.String("infoDirectory", "", "If a path is specified, the analyzer will output two files for each protocol containing basic rrp, flow, session and user information")
 var clusterModelDirectory = flag.String("clusterModelDirectory", "", "If a path is specified, the analyzer will load the clustering models from this path. The models will be used for clustering.")
-var statisticTCPReconstruction = flag.Bool("statisticTCPReconstruction", false, "If set, the analyzer will include statistics about the reconstruction in the metric file. This includes sizes of the reconstructed packets as well as speed.")
+var statisticTCPReconstruction = flag.Bool("statisticTCPReconstruction", false, "If set, the analyzer will include statistics about the reconstruction in the metric file. This includes sizes of the reconstructed packets as well as speed and the difference of the number of request/response pairs between the segmentation by sequence numbers and by direction changes.")
 var computeFlowRRPs = flag.Bool("flowRRPs", false, "If set, the analyzer will compute the size of rrps during the flow based analysis.")
+var exportAddresses = flag.String("exportAddresses", "hash", "Defines how IP addresses are exported. 'hash': only hashed addresses. 'plain': additionally export the real addresses as strings in the flow metrics and info files. 'cryptopan': only export prefix-preserving anonymized addresses (requires -cryptoPAnKey).")
+var cryptoPAnKeyFile = flag.String("cryptoPAnKey", "", "Path to the file containing the 32 byte (or 64 hex characters) Crypto-PAn key used by '-exportAddresses cryptopan'.")
+var flowExportFormat = flag.String("flowExportFormat", "json", "Format of the flow metrics file: 'json' (one object mapping the flow ID to its metrics) or 'ndjson' (one flow per line, can be processed incrementally e.g. by jq)")
+var flowExportCompression = flag.String("flowExportCompression", "none", "Compression of the flow metrics file: none, gzip or zstd")
+var flowExportMaxFileSize = flag.Int64("flowExportMaxFileSize", 0, "If set, the flow metrics are split into numbered files of about this size in bytes (after compression). (Default: 0 (one file))")
 var exportBufferSize = flag.Uint("exportBufferSize", 1000000, "Specified how many serialized flow metrics can be buffered before being written to the flow metrics json file.")
 
 func createMemoryProfile(suffix string) {
@@ -98,7 +137,9 @@ func checkFlags() {
 	if *interfaceName != "" && *exportDirectory == "" {
 		log.Fatalln("Abort program. Please specify a export Directory if you specify an interface to capture traffic from.")
 	} else if *exportDirectory == "" {
-		if utils.FileExists(*input) {
+		if *input == utils.StdinFilename {
+			*exportDirectory = "metrics"
+		} else if utils.FileExists(*input) {
 			*exportDirectory = path.Join(path.Dir(*input), "metrics")
 		} else {
 			*exportDirectory = path.Join(*input, "metrics")
@@ -116,8 +157,24 @@ func checkFlags() {
 		}
 	}
 
+	if *rotateInterval < 0 {
+		log.Fatalln("Abort program. The rotation interval must not be negative:", *rotateInterval)
+	}
+
+	if *durationClock != "trace" && *durationClock != "wall" {
+		log.Fatalln("Abort program. Unknown duration clock:", *durationClock)
+	}
+
+	if *captureBackend != "pcap" && *captureBackend != "afpacket" {
+		log.Fatalln("Abort program. Unknown capture backend:", *captureBackend)
+	}
+
 	if *statisticTCPReconstruction && !*tcpReconstructResponse {
-		log.Println("statisticTCPReconstruction can only be set in combination with the tcpReconstructResponse flag")
+		log.Println("Without the tcpReconstructResponse flag, statisticTCPReconstruction only compares the request/response segmentations")
+	}
+
+	if *tcpExcludeRetransmissions && *tcpSegmentBySequence {
+		log.Println("tcpExcludeRetransmissions has no effect in combination with tcpSegmentBySequence, which removes retransmissions anyway")
 	}
 }
 
@@ -162,51 +219,118 @@ func main() {
 	flows.TCPRstTimeout = tcpRstTimeout.Nanoseconds()
 	flows.TCPFinTimeout = tcpFinTimeout.Nanoseconds()
 	flows.UDPTimeout = udpTimeout.Nanoseconds()
-	pools := pool.NewPools(utils.ExpandIntegerList(*tcpFilter), utils.ExpandIntegerList(*udpFilter), *tcpDropIncomplete)
+	flows.SCTPTimeout = sctpTimeout.Nanoseconds()
+	flows.SCTPAbortTimeout = sctpAbortTimeout.Nanoseconds()
+	flows.SCTPShutdownTimeout = sctpShutdownTimeout.Nanoseconds()
+	flows.ICMPTimeout = icmpTimeout.Nanoseconds()
+	pools := pool.NewPools(utils.ExpandIntegerList(*tcpFilter), utils.ExpandIntegerList(*udpFilter), *tcpDropIncomplete, *flowFilter)
 
 	// Initialize Parser
-	packetParser := parser.NewParser(pools, sortingRingBufferSize, numParser, *samplingrate, numParserChannel)
+	packetParser := parser.NewParser(pools, sortingRingBufferSize, numParser, *samplingrate, numParserChannel, utils.ExpandIntegerList(*quicPorts), strings.Split(*decapsulate, ","))
+	if *reassembleFragments {
+		packetParser.EnableReassembly(fragmentTimeout.Nanoseconds(), *fragmentMemory)
+	}
 
 	// Initialize Metrics
+	addressExporter := common.NewAddressExporter(*exportAddresses, *cryptoPAnKeyFile)
 	if *computeFlowMetrics {
-		flowMetric = flowMetrics.NewMetric(*samplingrateFlows, *computeFlowRRPs, *exportBufferSize)
+		flowMetric = flowMetrics.NewMetric(*samplingrateFlows, *computeFlowRRPs, *exportBufferSize, addressExporter)
 		pools.RegisterMetric(flowMetric)
+		flowMetric.SetExportFormat(*flowExportFormat, *flowExportCompression, *flowExportMaxFileSize)
 		go flowMetric.ExportRoutine(*exportDirectory)
 	} else {
 		standardMetric = standardMetrics.NewMetric(
 			sessionTimeout.Nanoseconds(), *infoDirectory,
 			*clusterModelDirectory, *dropUnidirectional,
-			*tcpReconstructResponse, *statisticTCPReconstruction,
+			*tcpReconstructResponse, *statisticTCPReconstruction, *tcpExcludeRetransmissions,
+			*tcpSegmentBySequence,
+			addressExporter,
 		)
 		pools.RegisterMetric(standardMetric)
 	}
 
 	// Initialize Reader
-	var packetReader = reader.NewPacketReader(pools, packetParser)
-
-	if *input != "" {
-		for _, pcapFile := range utils.GetPcapFiles(*input) {
-			fmt.Println("Read file: ", pcapFile)
-			fmt.Println("Already read", humanize.Comma(packetReader.PacketIdx), "packets")
+	var packetReader = reader.NewPacketReader(pools, packetParser, strings.Split(*captureInterfaces, ","))
+	stopConditions := reader.StopConditions{MaxPackets: *maxPackets, MaxBytes: *maxBytes}
+	if *durationClock == "wall" {
+		stopConditions.MaxWallClockDuration = *maxDuration
+	} else {
+		stopConditions.MaxTraceDuration = *maxDuration
+	}
+	packetReader.SetStopConditions(stopConditions)
+	if *rotateInterval > 0 {
+		packetReader.SetRotation(*rotateInterval, rotateMetrics)
+	}
 
-			packetDataSource, ioHandle, deleteFile, fileName := reader.ReadPcapFile(pcapFile)
-			packetStopReached := packetReader.Read(packetStop, flushRate, packetDataSource)
+	// Stop reading on SIGINT/SIGTERM, the packets read so far are analyzed and exported as usual.
+	// A second signal aborts immediately.
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Println("Received", sig, ": Stop reading packets and export the metrics. Repeat to abort without export.")
+		packetReader.Stop()
+		sig = <-signals
+		log.Fatalln("Received", sig, ": Abort")
+	}()
 
-			// Delete uncompressed filed
-			if deleteFile {
-				_ = os.Remove(fileName)
+	if *input != "" {
+		packetReader.SetBPFFilter(*bpfFilter)
+		if *mergeInput {
+			// Read all files at once and merge their packets by timestamp
+			var packetDataSources []reader.PacketDataSource
+			var ioHandles []io.Closer
+			for _, pcapFile := range utils.GetPcapFiles(*input) {
+				fmt.Println("Merge file: ", pcapFile)
+				packetDataSource, ioHandle := reader.ReadPcapFile(pcapFile)
+				packetDataSources = append(packetDataSources, packetDataSource)
+				ioHandles = append(ioHandles, ioHandle)
+			}
+			packetReader.Read(flushRate, reader.NewMergedPacketDataSource(packetDataSources))
+			for _, ioHandle := range ioHandles {
+				_ = ioHandle.Close()
 			}
-			_ = ioHandle.Close()
-			if packetStopReached {
-				break
+		} else {
+			for _, pcapFile := range utils.GetPcapFiles(*input) {
+				fmt.Println("Read file: ", pcapFile)
+				fmt.Println("Already read", humanize.Comma(packetReader.PacketIdx), "packets")
+
+				packetDataSource, ioHandle := reader.ReadPcapFile(pcapFile)
+				stopReached := packetReader.Read(flushRate, packetDataSource)
+				_ = ioHandle.Close()
+				if stopReached {
+					break
+				}
 			}
 		}
+	} else if *captureBackend == "afpacket" {
+		afpacketSource := reader.NewAFPacketSource(*interfaceName, *afpacketBlockSize, *afpacketNumBlocks, *fanoutGroup, *fanoutMode)
+		if !parser.IsLinkTypeSupported(afpacketSource.LinkType()) {
+			log.Fatalln("Unsupported link type of interface", *interfaceName, ":", afpacketSource.LinkType())
+		}
+		if *bpfFilter != "" {
+			if err := afpacketSource.SetBPFFilter(*bpfFilter); err != nil {
+				log.Fatalln("Could not set BPF filter", *bpfFilter, ":", err)
+			}
+		}
+		packetReader.Read(flushRate, afpacketSource)
+		reader.PrintCaptureStatistics(afpacketSource)
+		afpacketSource.Close()
 	} else {
-		handle, err := pcap.OpenLive(*interfaceName, 152200, true, pcap.BlockForever)
+		handle, err := pcap.OpenLive(*interfaceName, 152200, true, reader.LiveReadTimeout)
 		if err != nil {
 			panic(err)
 		}
-		packetReader.Read(packetStop, flushRate, handle)
+		if !parser.IsLinkTypeSupported(handle.LinkType()) {
+			log.Fatalln("Unsupported link type of interface", *interfaceName, ":", handle.LinkType())
+		}
+		if *bpfFilter != "" {
+			if err := handle.SetBPFFilter(*bpfFilter); err != nil {
+				log.Fatalln("Could not set BPF filter", *bpfFilter, ":", err)
+			}
+		}
+		packetReader.Read(flushRate, handle)
+		reader.PrintCaptureStatistics(handle)
 		handle.Close()
 	}
 
@@ -216,7 +340,11 @@ func main() {
 
 	packetParser.Close()
 	fmt.Println("Decoded\t\t\t\t", humanize.Comma(packetReader.PacketIdx), "packets")
+	if *bpfFilter != "" && *input != "" {
+		fmt.Println("Rejected by BPF filter:\t\t", humanize.Comma(packetReader.NumBPFRejected), "packets")
+	}
 	fmt.Println("Time until Parsing Completed:\t", time.Since(startTime))
+	packetParser.PrintStatistics()
 	pools.PrintStatistics()
 
 	pools.Close()
@@ -234,15 +362,47 @@ func main() {
 		standardMetric.MetricInterSessions.PrintStatistic(false)
 		standardMetric.MetricNumFlows.PrintStatistic(false)
 		standardMetric.MetricInterFlowTimes.PrintStatistic(false)
+		standardMetric.MetricRTT.PrintStatistic(false)
+		standardMetric.MetricLossRate.PrintStatistic(false)
+		standardMetric.MetricConnectionState.PrintStatistic(false)
 		standardMetric.ReqResIdentifier.PrintStatistic(false)
 	}
 
+	// The metrics of the last rotation interval are exported to its directory
+	finalDirectory := *exportDirectory
+	if *rotateInterval > 0 && packetReader.RotationIntervalStart() != 0 {
+		finalDirectory = getRotationDirectory(packetReader.RotationIntervalStart())
+	}
+
 	if *computeFlowMetrics {
 		flowMetric.Flush()
 		flowMetric.Wait()
+		if finalDirectory != *exportDirectory {
+			flowMetric.MoveExportFiles(finalDirectory)
+		}
 	} else {
 		fmt.Println("Time until export start:", time.Since(startTime))
-		standardMetric.Export(*exportDirectory)
+		standardMetric.Export(finalDirectory)
 		fmt.Println("Time until export finished:", time.Since(startTime))
 	}
 }
+
+// rotateMetrics exports the metrics of the finished rotation interval and resets them
+func rotateMetrics(intervalStart int64) {
+	directory := getRotationDirectory(intervalStart)
+	fmt.Println("Rotate metrics to", directory)
+	if *computeFlowMetrics {
+		flowMetric.Rotate(directory)
+	} else {
+		standardMetric.Rotate(directory)
+	}
+}
+
+// getRotationDirectory returns (and creates) the export directory of the rotation interval starting at intervalStart
+func getRotationDirectory(intervalStart int64) string {
+	directory := path.Join(*exportDirectory, time.Unix(0, intervalStart).UTC().Format("2006-01-02T15-04-05Z"))
+	if !utils.DirectoryExists(directory) {
+		utils.CreateDir(directory)
+	}
+	return directory
+}
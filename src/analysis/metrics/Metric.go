@@ -7,4 +7,6 @@ import (
 type Metric interface {
 	OnTCPFlush(flow *flows.TCPFlow)
 	OnUDPFlush(flow *flows.UDPFlow)
+	OnSCTPFlush(flow *flows.SCTPFlow)
+	OnICMPFlush(flow *flows.ICMPFlow)
 }
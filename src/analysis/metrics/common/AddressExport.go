@@ -0,0 +1,87 @@
+package common
+
+import (
+	"scalable-flow-analyzer/anonymization"
+	"scalable-flow-analyzer/flows"
+	"log"
+	"net"
+	"strings"
+)
+
+// AddressExportMode defines how IP addresses are written to the exports.
+type AddressExportMode uint8
+
+const (
+	// AddressExportHash only exports the hashed addresses (Default)
+	AddressExportHash AddressExportMode = iota
+	// AddressExportPlain additionally exports the real addresses as strings
+	AddressExportPlain
+	// AddressExportCryptoPAn exports prefix-preserving anonymized addresses as strings.
+	// The hashes are not exported, since the unkeyed hash of an IPv4 address can be reverted by brute force.
+	AddressExportCryptoPAn
+)
+
+// AddressExporter formats the IP addresses written to the exports. It is safe for concurrent use.
+type AddressExporter struct {
+	mode      AddressExportMode
+	cryptoPAn *anonymization.CryptoPAn
+}
+
+// NewAddressExporter creates a new AddressExporter for the mode used on the command line ('hash', 'plain' or 'cryptopan').
+// keyFile is only used by the 'cryptopan' mode and must contain the 32 byte Crypto-PAn key.
+func NewAddressExporter(mode, keyFile string) *AddressExporter {
+	switch strings.ToLower(mode) {
+	case "", "hash":
+		return &AddressExporter{mode: AddressExportHash}
+	case "plain":
+		return &AddressExporter{mode: AddressExportPlain}
+	case "cryptopan":
+		if keyFile == "" {
+			log.Fatalln("The cryptopan address export mode requires a key file")
+		}
+		key, err := anonymization.LoadCryptoPAnKey(keyFile)
+		if err != nil {
+			log.Fatalln("Could not load Crypto-PAn key:", err)
+		}
+		cryptoPAn, err := anonymization.NewCryptoPAn(key)
+		if err != nil {
+			log.Fatalln("Could not initialize Crypto-PAn:", err)
+		}
+		return &AddressExporter{mode: AddressExportCryptoPAn, cryptoPAn: cryptoPAn}
+	default:
+		log.Fatalln("Unknown address export mode:", mode)
+		return nil
+	}
+}
+
+// ExportsHashes returns whether the hashed addresses (flows.Flow.ClientAddr/ServerAddr) may be exported
+func (ae *AddressExporter) ExportsHashes() bool {
+	return ae.mode != AddressExportCryptoPAn
+}
+
+// FormatHash returns the hashed address as it shall be written to the exports.
+// Returns 0 (omitted by protobuf) if hashes must not be exported.
+func (ae *AddressExporter) FormatHash(hash uint64) uint64 {
+	if !ae.ExportsHashes() {
+		return 0
+	}
+	return hash
+}
+
+// ExportsAddresses returns whether (possibly anonymized) addresses are exported as strings
+func (ae *AddressExporter) ExportsAddresses() bool {
+	return ae.mode != AddressExportHash
+}
+
+// FormatAddress returns the address as it shall be written to the exports.
+// Returns an empty string if only hashes are exported.
+func (ae *AddressExporter) FormatAddress(address flows.IPAddress) string {
+	switch ae.mode {
+	case AddressExportPlain:
+		return address.String()
+	case AddressExportCryptoPAn:
+		return net.IP(ae.cryptoPAn.Anonymize(address.Bytes())).String()
+	default:
+		return ""
+	}
+}
@@ -66,6 +66,13 @@ func (im *IntMetric) Export(protocolKey ProtocolKeyType) int {
 	return exportValue
 }
 
+// Reset removes all values of the Metric, e.g. after they have been exported for a rotation interval
+func (im *IntMetric) Reset() {
+	im.mutex.Lock()
+	im.protocolMetrics = make(map[ProtocolKeyType]*intMetricProtocol)
+	im.mutex.Unlock()
+}
+
 // Export the Protocols
 func (im *IntMetric) GetProtocols() []Protocol {
 	var protocols = make([]Protocol, 0)
@@ -158,6 +158,13 @@ func (imb *IntMetricBivariate) ExportClusters(protocolKey ProtocolKeyType) *Expo
 	return export
 }
 
+// Reset removes all values of the Metric, e.g. after they have been exported for a rotation interval
+func (imb *IntMetricBivariate) Reset() {
+	imb.mutex.Lock()
+	imb.protocolMetrics = make(map[ProtocolKeyType]*intMetricBivariateProtocol)
+	imb.mutex.Unlock()
+}
+
 // Export the Protocols
 func (imb *IntMetricBivariate) GetProtocols() []Protocol {
 	var protocols = make([]Protocol, 0)
@@ -137,6 +137,13 @@ func (imu *IntMetricUnivariate) ExportClusters(protocolKey ProtocolKeyType) *Exp
 	return export
 }
 
+// Reset removes all values of the Metric, e.g. after they have been exported for a rotation interval
+func (imu *IntMetricUnivariate) Reset() {
+	imu.mutex.Lock()
+	imu.protocolMetrics = make(map[ProtocolKeyType]*intMetricUnivariateProtocol)
+	imu.mutex.Unlock()
+}
+
 // Export the Protocols
 func (imu *IntMetricUnivariate) GetProtocols() []Protocol {
 	var protocols = make([]Protocol, 0)
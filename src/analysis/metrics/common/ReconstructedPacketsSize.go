@@ -40,6 +40,11 @@ func (mrp *MetricReconstructedPacketsSize) Name() string {
 	return "ReconstructedPacketsSize"
 }
 
+// Reset removes all values of the metric
+func (mrp *MetricReconstructedPacketsSize) Reset() {
+	mrp.size.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mrp *MetricReconstructedPacketsSize) PrintStatistic(verbose bool) {
 	fmt.Println("Metric ReconstructedPackets:")
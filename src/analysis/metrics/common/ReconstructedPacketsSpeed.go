@@ -37,6 +37,11 @@ func (mrp *MetricReconstructedPacketsSpeed) Name() string {
 	return "ReconstructedPacketsSpeed"
 }
 
+// Reset removes all values of the metric
+func (mrp *MetricReconstructedPacketsSpeed) Reset() {
+	mrp.speed.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mrp *MetricReconstructedPacketsSpeed) PrintStatistic(verbose bool) {
 	fmt.Println("Metric ReconstructedPackets:")
@@ -17,25 +17,44 @@ type RequestResponse struct {
 	Requests     []flows.Packet
 	Responses    []flows.Packet
 	ClusterIndex int
+	// Payload bytes of the requests/responses, which were retransmitted (TCP only).
+	// Only set if ExcludeTCPRetransmissions is enabled. Not set if segmented by sequence number, which removes retransmissions.
+	RetransmittedRequestBytes  int
+	RetransmittedResponseBytes int
 }
 
 type ReqResIdentifier struct {
 	DropUnidirectionalFlows      bool
 	ReconstructTCPResponse       bool
+	ExcludeTCPRetransmissions    bool
+	SegmentTCPBySequence         bool
 	numReconstructedPackets      IntMetric
+	numDuplicateBytes            IntMetric
+	numReorderedSegments         IntMetric
 	statisticReconstructionSpeed *MetricReconstructedPacketsSpeed
 	statisticReconstructionSize  *MetricReconstructedPacketsSize
+	statisticSegmentation        *MetricSegmentationComparison
 }
 
-func NewReqResIdentifier(dropUnidirectionalFlows, reconstructTCPResponse bool,
+// NewReqResIdentifier creates a new ReqResIdentifier.
+// If excludeTCPRetransmissions is set, the retransmitted bytes of each request and response are determined.
+// If segmentTCPBySequence is set, TCP payload is ordered and de-duplicated by sequence number before it is split.
+// If statisticSegmentation is set, both segmentations are computed for each TCP flow and compared.
+func NewReqResIdentifier(dropUnidirectionalFlows, reconstructTCPResponse, excludeTCPRetransmissions, segmentTCPBySequence bool,
 	statisticReconstructionSpeed *MetricReconstructedPacketsSpeed,
-	statisticReconstructionSize *MetricReconstructedPacketsSize) *ReqResIdentifier {
+	statisticReconstructionSize *MetricReconstructedPacketsSize,
+	statisticSegmentation *MetricSegmentationComparison) *ReqResIdentifier {
 	var rri = &ReqResIdentifier{
 		DropUnidirectionalFlows:      dropUnidirectionalFlows,
 		ReconstructTCPResponse:       reconstructTCPResponse,
+		ExcludeTCPRetransmissions:    excludeTCPRetransmissions,
+		SegmentTCPBySequence:         segmentTCPBySequence,
 		numReconstructedPackets:      NewIntMetric(),
+		numDuplicateBytes:            NewIntMetric(),
+		numReorderedSegments:         NewIntMetric(),
 		statisticReconstructionSpeed: statisticReconstructionSpeed,
 		statisticReconstructionSize:  statisticReconstructionSize,
+		statisticSegmentation:        statisticSegmentation,
 	}
 	return rri
 }
@@ -125,7 +144,7 @@ func (rri *ReqResIdentifier) reconstructFlow(protocol Protocol, flow *flows.TCPF
 
 			flowPackets[i] = flows.Packet{
 				FromClient:    false,
-				LengthPayload: newPackets[idxNew].size,
+				LengthPayload: uint32(newPackets[idxNew].size),
 				PacketIdx:     0,
 				Timestamp:     timestamp,
 			}
@@ -187,6 +206,28 @@ func (rri *ReqResIdentifier) OnTCPFlush(protocol Protocol, flow *flows.TCPFlow)
 		return reqRes, true
 	}
 
+	if !rri.SegmentTCPBySequence && rri.statisticSegmentation == nil {
+		return rri.segmentByDirection(flow), false
+	}
+	reqResBySequence := rri.segmentBySequence(protocol, flow)
+	if rri.statisticSegmentation != nil {
+		reqResByDirection := rri.segmentByDirection(flow)
+		rri.statisticSegmentation.addFlow(protocol, len(reqResBySequence), len(reqResByDirection))
+		if !rri.SegmentTCPBySequence {
+			return reqResByDirection, false
+		}
+	}
+	return reqResBySequence, false
+}
+
+// segmentByDirection identifies the request/response pairs of a TCP flow by the direction changes of the payload in capture order.
+// If ExcludeTCPRetransmissions is set, the retransmitted bytes of each request and response are determined.
+func (rri *ReqResIdentifier) segmentByDirection(flow *flows.TCPFlow) (reqRes []*RequestResponse) {
+	var loss TCPLoss
+	if rri.ExcludeTCPRetransmissions {
+		loss = GetTCPLoss(flow)
+	}
+
 	// Identify Request/Response pairs
 	var lastPacketWasRequest = false
 	for i, packet := range flow.Packets {
@@ -194,12 +235,17 @@ func (rri *ReqResIdentifier) OnTCPFlush(protocol Protocol, flow *flows.TCPFlow)
 		if isTCPControlPacket(packet, flow.TCPPacket[i]) {
 			continue
 		}
+		var retransmittedBytes int
+		if rri.ExcludeTCPRetransmissions {
+			retransmittedBytes = int(loss.Packets[i].RetransmittedBytes)
+		}
 		if packet.FromClient {
 			// Request
 			if !lastPacketWasRequest {
 				reqRes = append(reqRes, &RequestResponse{})
 			}
 			reqRes[len(reqRes)-1].Requests = append(reqRes[len(reqRes)-1].Requests, packet)
+			reqRes[len(reqRes)-1].RetransmittedRequestBytes += retransmittedBytes
 			lastPacketWasRequest = true
 		} else {
 			// Response
@@ -209,14 +255,33 @@ func (rri *ReqResIdentifier) OnTCPFlush(protocol Protocol, flow *flows.TCPFlow)
 			}
 			lastPacketWasRequest = false
 			reqRes[len(reqRes)-1].Responses = append(reqRes[len(reqRes)-1].Responses, packet)
+			reqRes[len(reqRes)-1].RetransmittedResponseBytes += retransmittedBytes
 		}
 	}
 
-	return reqRes, false
+	return reqRes
 }
 
 // onFlush Identifies the request response pairs per flow. If it is a UDP Flow, tcpPacket is nil.
 func (rri *ReqResIdentifier) OnUDPFlush(protocol Protocol, flow *flows.UDPFlow) (reqRes []*RequestResponse, dropFlow bool) {
+	return rri.onFlush(&flow.Flow, false)
+}
+
+// OnSCTPFlush Identifies the request response pairs of an SCTP association.
+// Packets without user data (e.g. SACK, HEARTBEAT or handshake chunks) are ignored, like TCP control packets.
+func (rri *ReqResIdentifier) OnSCTPFlush(protocol Protocol, flow *flows.SCTPFlow) (reqRes []*RequestResponse, dropFlow bool) {
+	return rri.onFlush(&flow.Flow, true)
+}
+
+// OnICMPFlush Identifies the request response pairs of an ICMP flow.
+// Echo requests are sent by the client, so each echo request and its echo reply form a request/response pair.
+func (rri *ReqResIdentifier) OnICMPFlush(protocol Protocol, flow *flows.ICMPFlow) (reqRes []*RequestResponse, dropFlow bool) {
+	return rri.onFlush(&flow.Flow, false)
+}
+
+// onFlush Identifies the request response pairs of a flow solely based on the direction of its packets.
+// If ignoreEmptyPackets is set, packets without payload are not part of any request or response.
+func (rri *ReqResIdentifier) onFlush(flow *flows.Flow, ignoreEmptyPackets bool) (reqRes []*RequestResponse, dropFlow bool) {
 	var hasRequest bool
 	var hasResponse bool
 
@@ -239,6 +304,9 @@ func (rri *ReqResIdentifier) OnUDPFlush(protocol Protocol, flow *flows.UDPFlow)
 	// Identify Request/Response pairs
 	var lastPacketWasRequest = false
 	for _, packet := range flow.Packets {
+		if ignoreEmptyPackets && packet.LengthPayload == 0 {
+			continue
+		}
 		if packet.FromClient {
 			// Request
 			if !lastPacketWasRequest {
@@ -263,4 +331,13 @@ func (rri *ReqResIdentifier) OnUDPFlush(protocol Protocol, flow *flows.UDPFlow)
 func (rri *ReqResIdentifier) PrintStatistic(verbose bool) {
 	fmt.Println("Number of reconstructed packets:")
 	fmt.Print(rri.numReconstructedPackets.GetStatistics(true))
+	if rri.SegmentTCPBySequence {
+		fmt.Println("Number of duplicate bytes removed by sequence number:")
+		fmt.Print(rri.numDuplicateBytes.GetStatistics(true))
+		fmt.Println("Number of segments reordered by sequence number:")
+		fmt.Print(rri.numReorderedSegments.GetStatistics(true))
+	}
+	if rri.statisticSegmentation != nil {
+		rri.statisticSegmentation.PrintStatistic(verbose)
+	}
 }
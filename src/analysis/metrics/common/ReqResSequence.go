@@ -0,0 +1,157 @@
+package common
+
+// Identifies request/response pairs of TCP flows based on sequence numbers instead of the capture order.
+
+import (
+	"scalable-flow-analyzer/flows"
+	"sort"
+)
+
+// tcpSegment is the new payload of a packet after de-duplication by sequence number.
+// Offsets are relative to the first sequence number of the direction and extended to 64 bits (see unwrapSequence),
+// so they keep growing after the sequence numbers wrap around.
+type tcpSegment struct {
+	packet    flows.Packet // LengthPayload is reduced to the new payload
+	offset    int64        // Offset of the first new byte
+	end       int64        // Offset following the segment
+	ackOffset int64        // Acknowledged offset of the other direction, only valid if hasAck is set
+	hasAck    bool
+	index     int // Index of the packet in the flow
+}
+
+// getSequenceBase returns the sequence number of the first payload byte of the direction.
+// The second return value is false, if the direction did not send any packet.
+func getSequenceBase(flow *flows.TCPFlow, fromClient bool) (uint32, bool) {
+	for i, packet := range flow.Packets {
+		if packet.FromClient != fromClient {
+			continue
+		}
+		tcpPacket := flow.TCPPacket[i]
+		if tcpPacket.SYN {
+			return tcpPacket.SeqNr + 1, true
+		}
+		return tcpPacket.SeqNr, true
+	}
+	return 0, false
+}
+
+// unwrapSequence returns the offset of the sequence number relative to base, extended to 64 bits.
+// The sequence number is assumed to be less than 2 GiB away from the previous offset of the direction.
+func unwrapSequence(seqNr, base uint32, previousOffset int64) int64 {
+	return previousOffset + int64(int32(seqNr-(base+uint32(previousOffset))))
+}
+
+// getOrderedSegments returns the payload of one direction ordered by sequence number.
+// Payload, which was already sent (retransmissions), is removed.
+// Returns the number of removed bytes and the number of remaining segments, which arrived out of order.
+func getOrderedSegments(flow *flows.TCPFlow, fromClient bool, base, otherBase uint32) (segments []tcpSegment, duplicateBytes, reorderedSegments int) {
+	// Offsets of the previous packet in capture order. Packets without payload are included,
+	// so that the offsets are unwrapped even if the direction does not send payload for a long time.
+	var offset, ackOffset int64
+	for i, packet := range flow.Packets {
+		tcpPacket := flow.TCPPacket[i]
+		if packet.FromClient != fromClient {
+			continue
+		}
+		seqNr := tcpPacket.SeqNr
+		if tcpPacket.SYN {
+			seqNr++
+		}
+		offset = unwrapSequence(seqNr, base, offset)
+		if tcpPacket.ACK {
+			ackOffset = unwrapSequence(tcpPacket.AckNr, otherBase, ackOffset)
+		}
+		if packet.LengthPayload == 0 {
+			continue
+		}
+		segment := tcpSegment{
+			packet:    packet,
+			offset:    offset,
+			end:       offset + int64(packet.LengthPayload),
+			ackOffset: ackOffset,
+			hasAck:    tcpPacket.ACK,
+			index:     i,
+		}
+		segments = append(segments, segment)
+	}
+
+	// Stable, so retransmissions keep the order in which they were captured
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].offset < segments[j].offset
+	})
+
+	// Remove duplicate payload. Remaining segments captured after a segment with a higher offset arrived out of order.
+	var deduplicated []tcpSegment
+	var nextOffset int64
+	var highestIndex int
+	for _, segment := range segments {
+		if len(deduplicated) > 0 && segment.end <= nextOffset {
+			duplicateBytes += int(segment.packet.LengthPayload)
+			continue
+		}
+		if len(deduplicated) > 0 && segment.offset < nextOffset {
+			duplicateBytes += int(nextOffset - segment.offset)
+			segment.offset = nextOffset
+			segment.packet.LengthPayload = uint32(segment.end - segment.offset)
+		}
+		if segment.index < highestIndex {
+			reorderedSegments++
+		} else {
+			highestIndex = segment.index
+		}
+		nextOffset = segment.end
+		deduplicated = append(deduplicated, segment)
+	}
+	return deduplicated, duplicateBytes, reorderedSegments
+}
+
+// segmentBefore returns whether segment a was sent before segment b of the other direction.
+// If a segment acknowledges the other one completely, it was sent afterwards. Otherwise the capture order is used.
+func segmentBefore(a, b tcpSegment) bool {
+	switch {
+	case b.hasAck && b.ackOffset >= a.end:
+		return true
+	case a.hasAck && a.ackOffset >= b.end:
+		return false
+	case a.packet.Timestamp != b.packet.Timestamp:
+		return a.packet.Timestamp < b.packet.Timestamp
+	}
+	return a.index < b.index
+}
+
+// segmentBySequence identifies the request/response pairs of a TCP flow.
+// The payload of each direction is ordered and de-duplicated by sequence number.
+// Both directions are merged based on the ACK numbers, before requests and responses are split on direction changes.
+func (rri *ReqResIdentifier) segmentBySequence(protocol Protocol, flow *flows.TCPFlow) (reqRes []*RequestResponse) {
+	clientBase, _ := getSequenceBase(flow, true)
+	serverBase, _ := getSequenceBase(flow, false)
+	requests, duplicateRequestBytes, reorderedRequests := getOrderedSegments(flow, true, clientBase, serverBase)
+	responses, duplicateResponseBytes, reorderedResponses := getOrderedSegments(flow, false, serverBase, clientBase)
+	// Not recorded if the segmentation is only computed for the comparison (see MetricSegmentationComparison)
+	if rri.SegmentTCPBySequence {
+		rri.numDuplicateBytes.AddValue(protocol, duplicateRequestBytes+duplicateResponseBytes)
+		rri.numReorderedSegments.AddValue(protocol, reorderedRequests+reorderedResponses)
+	}
+
+	var lastPacketWasRequest = false
+	for len(requests) > 0 || len(responses) > 0 {
+		if len(responses) == 0 || (len(requests) > 0 && segmentBefore(requests[0], responses[0])) {
+			// Request
+			if !lastPacketWasRequest {
+				reqRes = append(reqRes, &RequestResponse{})
+			}
+			reqRes[len(reqRes)-1].Requests = append(reqRes[len(reqRes)-1].Requests, requests[0].packet)
+			lastPacketWasRequest = true
+			requests = requests[1:]
+		} else {
+			// Response
+			// ignore responses without requests (if we start capturing in the middle of the connection)
+			if len(reqRes) > 0 {
+				lastPacketWasRequest = false
+				reqRes[len(reqRes)-1].Responses = append(reqRes[len(reqRes)-1].Responses, responses[0].packet)
+			}
+			responses = responses[1:]
+		}
+	}
+	return reqRes
+}
@@ -0,0 +1,227 @@
+package common
+
+import (
+	"scalable-flow-analyzer/flows"
+	"testing"
+)
+
+// testSegment describes a packet of a TCP flow. Sequence and ACK numbers are offsets to the ISNs of the test flow.
+type testSegment struct {
+	fromClient bool
+	seq        uint32
+	length     uint32
+	ack        uint32
+}
+
+// Initial sequence numbers of the test flows, the client wraps around after 256 bytes
+const testClientISN = 0xffffff00 - 1
+const testServerISN = 1000 - 1
+
+// newTestFlow creates a TCP flow with a handshake followed by the segments in capture order
+func newTestFlow(segments []testSegment) *flows.TCPFlow {
+	flow := &flows.TCPFlow{}
+	add := func(packet flows.Packet, tcpPacket flows.TCPPacket) {
+		packet.Timestamp = int64(len(flow.Packets))
+		flow.Packets = append(flow.Packets, packet)
+		flow.TCPPacket = append(flow.TCPPacket, tcpPacket)
+	}
+	add(flows.Packet{FromClient: true}, flows.TCPPacket{SeqNr: testClientISN, SYN: true})
+	add(flows.Packet{}, flows.TCPPacket{SeqNr: testServerISN, AckNr: testClientISN + 1, SYN: true, ACK: true})
+	for _, segment := range segments {
+		seq, ack := uint32(testClientISN+1)+segment.seq, uint32(testServerISN+1)+segment.ack
+		if !segment.fromClient {
+			seq, ack = uint32(testServerISN+1)+segment.seq, uint32(testClientISN+1)+segment.ack
+		}
+		add(flows.Packet{FromClient: segment.fromClient, LengthPayload: segment.length}, flows.TCPPacket{SeqNr: seq, AckNr: ack, ACK: true})
+	}
+	return flow
+}
+
+func TestUnwrapSequence(t *testing.T) {
+	tests := []struct {
+		name           string
+		seqNr, base    uint32
+		previousOffset int64
+		offset         int64
+	}{
+		{name: "start", seqNr: 100, base: 100, previousOffset: 0, offset: 0},
+		{name: "forward", seqNr: 1100, base: 100, previousOffset: 0, offset: 1000},
+		{name: "backward", seqNr: 100, base: 100, previousOffset: 1000, offset: 0},
+		{name: "wrap around", seqNr: 10, base: 0xfffffff0, previousOffset: 0, offset: 26},
+		{name: "beyond 4 GiB", seqNr: 100, base: 100, previousOffset: 1<<32 - 10, offset: 1 << 32},
+		{name: "retransmission beyond 4 GiB", seqNr: 90, base: 100, previousOffset: 1 << 32, offset: 1<<32 - 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if offset := unwrapSequence(test.seqNr, test.base, test.previousOffset); offset != test.offset {
+				t.Errorf("unwrapSequence = %d, want %d", offset, test.offset)
+			}
+		})
+	}
+}
+
+func TestGetOrderedSegments(t *testing.T) {
+	tests := []struct {
+		name              string
+		segments          []testSegment
+		offsets           []int64
+		lengths           []uint32
+		duplicateBytes    int
+		reorderedSegments int
+	}{
+		{
+			name:     "in order",
+			segments: []testSegment{{true, 0, 100, 0}, {true, 100, 100, 0}, {true, 200, 100, 0}},
+			offsets:  []int64{0, 100, 200},
+			lengths:  []uint32{100, 100, 100},
+		},
+		{
+			name:              "out of order",
+			segments:          []testSegment{{true, 0, 100, 0}, {true, 200, 100, 0}, {true, 100, 100, 0}},
+			offsets:           []int64{0, 100, 200},
+			lengths:           []uint32{100, 100, 100},
+			reorderedSegments: 1,
+		},
+		{
+			name:           "retransmission",
+			segments:       []testSegment{{true, 0, 100, 0}, {true, 100, 100, 0}, {true, 0, 100, 0}},
+			offsets:        []int64{0, 100},
+			lengths:        []uint32{100, 100},
+			duplicateBytes: 100,
+		},
+		{
+			name:           "overlapping retransmission",
+			segments:       []testSegment{{true, 0, 100, 0}, {true, 50, 100, 0}},
+			offsets:        []int64{0, 100},
+			lengths:        []uint32{100, 50},
+			duplicateBytes: 50,
+		},
+		{
+			name:     "wrap around",
+			segments: []testSegment{{true, 0, 200, 0}, {true, 200, 200, 0}, {true, 400, 200, 0}},
+			offsets:  []int64{0, 200, 400},
+			lengths:  []uint32{200, 200, 200},
+		},
+		{
+			name: "beyond 4 GiB",
+			segments: []testSegment{{true, 0, 1 << 30, 0}, {true, 1 << 30, 1 << 30, 0}, {true, 2 << 30, 1 << 30, 0},
+				{true, 3 << 30, 1 << 30, 0}, {true, 0, 1 << 30, 0}, {true, 1 << 30, 100, 0}},
+			offsets: []int64{0, 1 << 30, 2 << 30, 3 << 30, 4 << 30, 5 << 30},
+			lengths: []uint32{1 << 30, 1 << 30, 1 << 30, 1 << 30, 1 << 30, 100},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			flow := newTestFlow(test.segments)
+			base, _ := getSequenceBase(flow, true)
+			otherBase, _ := getSequenceBase(flow, false)
+			segments, duplicateBytes, reorderedSegments := getOrderedSegments(flow, true, base, otherBase)
+			if len(segments) != len(test.offsets) {
+				t.Fatalf("got %d segments, want %d", len(segments), len(test.offsets))
+			}
+			for i, segment := range segments {
+				if segment.offset != test.offsets[i] || segment.packet.LengthPayload != test.lengths[i] {
+					t.Errorf("segment %d: offset %d, length %d, want offset %d, length %d",
+						i, segment.offset, segment.packet.LengthPayload, test.offsets[i], test.lengths[i])
+				}
+			}
+			if duplicateBytes != test.duplicateBytes {
+				t.Errorf("duplicateBytes = %d, want %d", duplicateBytes, test.duplicateBytes)
+			}
+			if reorderedSegments != test.reorderedSegments {
+				t.Errorf("reorderedSegments = %d, want %d", reorderedSegments, test.reorderedSegments)
+			}
+		})
+	}
+}
+
+func TestSegmentBySequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []testSegment
+		// Number of requests and responses of each request/response pair
+		bySequence  [][2]int
+		byDirection [][2]int
+	}{
+		{
+			name:        "request response",
+			segments:    []testSegment{{true, 0, 100, 0}, {false, 0, 200, 100}, {true, 100, 100, 200}, {false, 200, 200, 200}},
+			bySequence:  [][2]int{{1, 1}, {1, 1}},
+			byDirection: [][2]int{{1, 1}, {1, 1}},
+		},
+		{
+			name:        "pipelining",
+			segments:    []testSegment{{true, 0, 100, 0}, {true, 100, 100, 0}, {false, 0, 200, 100}, {false, 200, 200, 200}},
+			bySequence:  [][2]int{{2, 2}},
+			byDirection: [][2]int{{2, 2}},
+		},
+		{
+			name:        "response captured before its request",
+			segments:    []testSegment{{true, 0, 100, 0}, {false, 0, 200, 200}, {true, 100, 100, 0}},
+			bySequence:  [][2]int{{2, 1}},
+			byDirection: [][2]int{{1, 1}, {1, 0}},
+		},
+		{
+			name:        "retransmitted request after the response",
+			segments:    []testSegment{{true, 0, 100, 0}, {false, 0, 200, 100}, {true, 0, 100, 200}},
+			bySequence:  [][2]int{{1, 1}},
+			byDirection: [][2]int{{1, 1}, {1, 0}},
+		},
+		{
+			name:        "out of order request",
+			segments:    []testSegment{{true, 100, 100, 0}, {true, 0, 100, 0}, {false, 0, 200, 200}},
+			bySequence:  [][2]int{{2, 1}},
+			byDirection: [][2]int{{2, 1}},
+		},
+	}
+	check := func(t *testing.T, segmentation string, reqRes []*RequestResponse, want [][2]int) {
+		if len(reqRes) != len(want) {
+			t.Fatalf("%s: got %d request/response pairs, want %d", segmentation, len(reqRes), len(want))
+		}
+		for i, pair := range reqRes {
+			if len(pair.Requests) != want[i][0] || len(pair.Responses) != want[i][1] {
+				t.Errorf("%s: pair %d has %d requests and %d responses, want %d and %d",
+					segmentation, i, len(pair.Requests), len(pair.Responses), want[i][0], want[i][1])
+			}
+		}
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bySequence := NewReqResIdentifier(false, false, false, true, nil, nil, nil)
+			reqRes, _ := bySequence.OnTCPFlush(Protocol{}, newTestFlow(test.segments))
+			check(t, "by sequence", reqRes, test.bySequence)
+
+			byDirection := NewReqResIdentifier(false, false, false, false, nil, nil, nil)
+			reqRes, _ = byDirection.OnTCPFlush(Protocol{}, newTestFlow(test.segments))
+			check(t, "by direction", reqRes, test.byDirection)
+		})
+	}
+}
+
+func TestSegmentationComparison(t *testing.T) {
+	// Retransmitted request after the response: One pair by sequence, two pairs by direction
+	segments := []testSegment{{true, 0, 100, 0}, {false, 0, 200, 100}, {true, 0, 100, 200}}
+
+	comparison := NewMetricSegmentationComparison()
+	rri := NewReqResIdentifier(false, false, false, false, nil, nil, comparison)
+	reqRes, _ := rri.OnTCPFlush(Protocol{}, newTestFlow(segments))
+	if len(reqRes) != 2 {
+		t.Errorf("got %d request/response pairs, want the segmentation by direction (2)", len(reqRes))
+	}
+	if values := comparison.Export(Protocol{}.ProtocolKey).Values; len(values) != 1 || values[0][0] != -1 || values[0][1] != 1 {
+		t.Errorf("difference %v, want one flow with difference -1", values)
+	}
+	// The statistics of the segmentation by sequence are only recorded if it is used
+	if duplicateBytes := rri.numDuplicateBytes.Export(Protocol{}.ProtocolKey); duplicateBytes != 0 {
+		t.Errorf("numDuplicateBytes = %d, want 0", duplicateBytes)
+	}
+
+	rri = NewReqResIdentifier(false, false, false, true, nil, nil, comparison)
+	reqRes, _ = rri.OnTCPFlush(Protocol{}, newTestFlow(segments))
+	if len(reqRes) != 1 {
+		t.Errorf("got %d request/response pairs, want the segmentation by sequence (1)", len(reqRes))
+	}
+	if duplicateBytes := rri.numDuplicateBytes.Export(Protocol{}.ProtocolKey); duplicateBytes != 100 {
+		t.Errorf("numDuplicateBytes = %d, want 100", duplicateBytes)
+	}
+}
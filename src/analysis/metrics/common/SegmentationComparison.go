@@ -0,0 +1,53 @@
+package common
+
+import (
+	"fmt"
+)
+
+// This metric compares the number of request/response pairs per TCP flow found by the segmentation by sequence numbers
+// with the number found by the segmentation by direction changes (-tcpSegmentBySequence). A negative difference means
+// that reordered or retransmitted segments split requests or responses, if they are segmented by direction changes.
+// Both segmentations of the same flow are only available when the ReqResIdentifier flushes it,
+// therefore the ReqResIdentifier feeds this metric directly instead of a flow metric hook.
+// It is enabled together with the reconstruction statistics (-statisticTCPReconstruction).
+
+type MetricSegmentationComparison struct {
+	difference IntMetricUnivariate
+}
+
+func NewMetricSegmentationComparison() *MetricSegmentationComparison {
+	var metricSegmentationComparison = MetricSegmentationComparison{}
+	metricSegmentationComparison.difference = NewIntMetricUnivariate(1, false)
+	return &metricSegmentationComparison
+}
+
+// addFlow adds the difference of the number of request/response pairs (by sequence - by direction) of a TCP flow
+func (msc *MetricSegmentationComparison) addFlow(p Protocol, numRRPsBySequence, numRRPsByDirection int) {
+	msc.difference.AddValue(p, DefaultClusterIndex, numRRPsBySequence-numRRPsByDirection)
+}
+
+// Export returns the metric data per Protocol
+func (msc *MetricSegmentationComparison) Export(protocolKey ProtocolKeyType) *ExportUnivariateFormat {
+	return msc.difference.Export(protocolKey, DefaultClusterIndex)
+}
+
+// Export the stored protocols
+func (msc *MetricSegmentationComparison) GetProtocols() []Protocol {
+	return msc.difference.GetProtocols()
+}
+
+// Name of the Metric
+func (msc *MetricSegmentationComparison) Name() string {
+	return "SegmentationRRPDifference"
+}
+
+// Reset removes all values of the metric
+func (msc *MetricSegmentationComparison) Reset() {
+	msc.difference.Reset()
+}
+
+// PrintStatistic prints some statistic to the console
+func (msc *MetricSegmentationComparison) PrintStatistic(verbose bool) {
+	fmt.Println("Metric Segmentation RRP Difference (by sequence - by direction):")
+	fmt.Print(msc.difference.GetStatistics(verbose))
+}
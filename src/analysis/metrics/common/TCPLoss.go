@@ -0,0 +1,198 @@
+package common
+
+import (
+	"scalable-flow-analyzer/flows"
+)
+
+// TCPPacketClass is the result of the sequence number analysis of a TCP packet
+type TCPPacketClass uint8
+
+const (
+	TCPPacketNormal TCPPacketClass = iota
+	// TCPPacketRetransmission contains data, which was already seen or which was lost before the capture point
+	TCPPacketRetransmission
+	// TCPPacketOutOfOrder fills a gap in the sequence space shortly (TCPReorderThreshold) after the gap occurred
+	TCPPacketOutOfOrder
+	// TCPPacketDupAck repeats the ACK number and window of the previous packet without data, while data is outstanding
+	TCPPacketDupAck
+	// TCPPacketZeroWindowProbe contains a single byte, while the receiver advertises a zero window
+	TCPPacketZeroWindowProbe
+	// TCPPacketKeepAlive contains at most one byte of already acknowledged data
+	TCPPacketKeepAlive
+)
+
+// TCPReorderThreshold in Nanoseconds. A segment filling a gap in the sequence space within this time is considered out of order,
+// otherwise it is considered a retransmission of a segment lost before the capture point.
+const TCPReorderThreshold = int64(3000000)
+
+// TCPDirectionLoss contains the number of classified packets sent in one direction of a TCP connection
+type TCPDirectionLoss struct {
+	DataPackets        int // Packets occupying sequence space (payload, SYN or FIN)
+	Retransmissions    int
+	RetransmittedBytes int
+	OutOfOrder         int
+	DupAcks            int
+	ZeroWindowProbes   int
+}
+
+// TCPLoss is the result of the sequence number analysis of a TCP connection.
+// Packets contains the classification of each packet of the flow.
+type TCPLoss struct {
+	Client  TCPDirectionLoss
+	Server  TCPDirectionLoss
+	Packets []TCPPacketLoss
+}
+
+// TCPPacketLoss is the classification of a single packet.
+// RetransmittedBytes can be smaller than the payload, if the packet also contains new data.
+type TCPPacketLoss struct {
+	Class              TCPPacketClass
+	RetransmittedBytes uint32
+}
+
+// LossRate returns the retransmissions per thousand data packets of both directions, -1 if the flow contains no data.
+func (loss *TCPLoss) LossRate() int {
+	dataPackets := loss.Client.DataPackets + loss.Server.DataPackets
+	if dataPackets == 0 {
+		return -1
+	}
+	return (loss.Client.Retransmissions + loss.Server.Retransmissions) * 1000 / dataPackets
+}
+
+// sequenceGap is a range of the sequence space, which was skipped at the time given by timestamp
+type sequenceGap struct {
+	start     uint32
+	end       uint32
+	timestamp int64
+}
+
+// tcpSequenceState is the state of the sequence space analysis of one direction
+type tcpSequenceState struct {
+	nextSeq     uint32 // Sequence number following the highest sequence number seen
+	gaps        []sequenceGap
+	lastAck     uint32
+	lastWindow  uint16
+	initialized bool
+	ackSeen     bool
+	zeroWindow  bool // Last advertised window was zero
+}
+
+// fillGap removes the range from the gaps and returns the time at which the first overlapping gap occurred.
+// Returns false if the range does not overlap with any gap.
+func (state *tcpSequenceState) fillGap(start, end uint32) (int64, bool) {
+	var timestamp int64
+	var found bool
+	var gaps []sequenceGap
+	for _, gap := range state.gaps {
+		if !seqAfter(end, gap.start) || !seqAfter(gap.end, start) {
+			gaps = append(gaps, gap)
+			continue
+		}
+		if !found {
+			timestamp = gap.timestamp
+			found = true
+		}
+		if seqAfter(start, gap.start) {
+			gaps = append(gaps, sequenceGap{start: gap.start, end: start, timestamp: gap.timestamp})
+		}
+		if seqAfter(gap.end, end) {
+			gaps = append(gaps, sequenceGap{start: end, end: gap.end, timestamp: gap.timestamp})
+		}
+	}
+	state.gaps = gaps
+	return timestamp, found
+}
+
+// GetTCPLoss classifies the packets of the TCP connection as retransmissions, out of order packets,
+// duplicate ACKs and zero window probes based on the sequence and ACK numbers of both directions.
+func GetTCPLoss(flow *flows.TCPFlow) TCPLoss {
+	loss := TCPLoss{Packets: make([]TCPPacketLoss, len(flow.Packets))}
+	// Index 0: Sent by client, 1: sent by server
+	var states [2]tcpSequenceState
+	for i, packet := range flow.Packets {
+		tcpPacket := flow.TCPPacket[i]
+		sender, receiver := 0, 1
+		directionLoss := &loss.Client
+		if !packet.FromClient {
+			sender, receiver = 1, 0
+			directionLoss = &loss.Server
+		}
+		state := &states[sender]
+		packetLoss := &loss.Packets[i]
+		if tcpPacket.RST {
+			continue
+		}
+
+		seqLength := packet.LengthPayload
+		if tcpPacket.SYN {
+			seqLength++
+		}
+		if tcpPacket.FIN {
+			seqLength++
+		}
+		endSeq := tcpPacket.SeqNr + seqLength
+		if seqLength > 0 {
+			directionLoss.DataPackets++
+		}
+
+		keepAlive := packet.LengthPayload <= 1 && !tcpPacket.SYN && !tcpPacket.FIN && state.initialized &&
+			tcpPacket.SeqNr+1 == state.nextSeq
+		switch {
+		case !state.initialized:
+			state.nextSeq = endSeq
+			state.initialized = true
+		case seqLength == 0:
+			// Pure ACK
+		case packet.LengthPayload == 1 && states[receiver].zeroWindow &&
+			(tcpPacket.SeqNr == state.nextSeq || tcpPacket.SeqNr+1 == state.nextSeq):
+			packetLoss.Class = TCPPacketZeroWindowProbe
+			directionLoss.ZeroWindowProbes++
+			if seqAfter(endSeq, state.nextSeq) {
+				state.nextSeq = endSeq
+			}
+		case keepAlive:
+			packetLoss.Class = TCPPacketKeepAlive
+		case seqAfter(tcpPacket.SeqNr, state.nextSeq):
+			// Segments before this one were lost before the capture point or are out of order
+			state.gaps = append(state.gaps, sequenceGap{start: state.nextSeq, end: tcpPacket.SeqNr, timestamp: packet.Timestamp})
+			state.nextSeq = endSeq
+		case seqAfter(endSeq, state.nextSeq):
+			// New data, which partially overlaps with data already seen
+			if tcpPacket.SeqNr != state.nextSeq {
+				packetLoss.Class = TCPPacketRetransmission
+				packetLoss.RetransmittedBytes = state.nextSeq - tcpPacket.SeqNr
+			}
+			state.nextSeq = endSeq
+		default:
+			// Only old data
+			if gapTimestamp, ok := state.fillGap(tcpPacket.SeqNr, endSeq); ok && packet.Timestamp-gapTimestamp < TCPReorderThreshold {
+				packetLoss.Class = TCPPacketOutOfOrder
+			} else {
+				packetLoss.Class = TCPPacketRetransmission
+				packetLoss.RetransmittedBytes = packet.LengthPayload
+			}
+		}
+
+		switch packetLoss.Class {
+		case TCPPacketRetransmission:
+			directionLoss.Retransmissions++
+			directionLoss.RetransmittedBytes += int(packetLoss.RetransmittedBytes)
+		case TCPPacketOutOfOrder:
+			directionLoss.OutOfOrder++
+		}
+
+		if !tcpPacket.ACK {
+			continue
+		}
+		if seqLength == 0 && !keepAlive && state.ackSeen && tcpPacket.AckNr == state.lastAck && tcpPacket.Window == state.lastWindow &&
+			states[receiver].initialized && seqAfter(states[receiver].nextSeq, tcpPacket.AckNr) {
+			packetLoss.Class = TCPPacketDupAck
+			directionLoss.DupAcks++
+		}
+		state.lastAck = tcpPacket.AckNr
+		state.lastWindow = tcpPacket.Window
+		state.zeroWindow = tcpPacket.Window == 0 && !tcpPacket.SYN
+		state.ackSeen = true
+	}
+	return loss
+}
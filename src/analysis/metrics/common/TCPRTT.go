@@ -0,0 +1,163 @@
+package common
+
+import (
+	"scalable-flow-analyzer/flows"
+)
+
+// TCPRTT contains the round-trip times (RTT) of a TCP connection in nanoseconds.
+// They are measured at the capture point, so the RTT is split into two parts:
+// The client-side RTT is the time between the capture point and the client,
+// the server-side RTT is the time between the capture point and the server.
+// The end-to-end RTT is the sum of both.
+type TCPRTT struct {
+	// Handshake RTT: SYN -> SYN/ACK (server-side) and SYN/ACK -> ACK (client-side). -1 if not observed.
+	HandshakeClient int64
+	HandshakeServer int64
+	// RTT samples from matching data segments with the ACKs acknowledging them.
+	// Client samples are measured on data sent by the server and vice versa.
+	ClientSamples []int64
+	ServerSamples []int64
+}
+
+// Handshake returns the end-to-end RTT of the handshake, -1 if the handshake was not observed completely.
+func (rtt *TCPRTT) Handshake() int64 {
+	if rtt.HandshakeClient < 0 || rtt.HandshakeServer < 0 {
+		return -1
+	}
+	return rtt.HandshakeClient + rtt.HandshakeServer
+}
+
+// MinClient returns the minimal client-side RTT of the handshake and all samples, -1 if there is none.
+func (rtt *TCPRTT) MinClient() int64 {
+	return minRTT(rtt.HandshakeClient, rtt.ClientSamples)
+}
+
+// MinServer returns the minimal server-side RTT of the handshake and all samples, -1 if there is none.
+func (rtt *TCPRTT) MinServer() int64 {
+	return minRTT(rtt.HandshakeServer, rtt.ServerSamples)
+}
+
+func minRTT(handshake int64, samples []int64) int64 {
+	min := handshake
+	for _, sample := range samples {
+		if min < 0 || sample < min {
+			min = sample
+		}
+	}
+	return min
+}
+
+// MeanRTT returns the mean of the samples, -1 if there are none.
+func MeanRTT(samples []int64) int64 {
+	if len(samples) == 0 {
+		return -1
+	}
+	var sum int64
+	for _, sample := range samples {
+		sum += sample
+	}
+	return sum / int64(len(samples))
+}
+
+// outstandingSegment is a data segment, which has not been acknowledged yet
+type outstandingSegment struct {
+	endSeq        uint32 // Sequence number following the segment
+	timestamp     int64
+	retransmitted bool
+}
+
+// seqAfter returns whether sequence number a is after b, taking wrap arounds into account
+func seqAfter(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+// GetTCPRTT computes the handshake RTT and the RTT samples of the TCP connection.
+// Data segments are matched with the first ACK which acknowledges them completely.
+// If multiple segments are acknowledged at once, only the last one is used (delayed ACKs).
+// Following Karn's algorithm, retransmitted segments are not sampled, since the ACK is ambiguous.
+func GetTCPRTT(flow *flows.TCPFlow) TCPRTT {
+	rtt := TCPRTT{HandshakeClient: -1, HandshakeServer: -1}
+	rtt.getHandshakeRTT(flow)
+
+	// Index 0: Sent by client, 1: sent by server
+	var outstanding [2][]outstandingSegment
+	var highestSeq [2]uint32
+	var dataSeen [2]bool
+	for i, packet := range flow.Packets {
+		tcpPacket := flow.TCPPacket[i]
+		sender, receiver := 0, 1
+		if !packet.FromClient {
+			sender, receiver = 1, 0
+		}
+
+		if packet.LengthPayload > 0 && !tcpPacket.SYN && !tcpPacket.RST {
+			endSeq := tcpPacket.SeqNr + packet.LengthPayload
+			if !dataSeen[sender] || seqAfter(endSeq, highestSeq[sender]) {
+				outstanding[sender] = append(outstanding[sender], outstandingSegment{endSeq: endSeq, timestamp: packet.Timestamp})
+				highestSeq[sender] = endSeq
+				dataSeen[sender] = true
+			} else {
+				// Retransmission, the ACK of all segments covered by it is ambiguous
+				for j := range outstanding[sender] {
+					if seqAfter(outstanding[sender][j].endSeq, tcpPacket.SeqNr) {
+						outstanding[sender][j].retransmitted = true
+					}
+				}
+			}
+		}
+
+		if !tcpPacket.ACK || tcpPacket.RST || len(outstanding[receiver]) == 0 {
+			continue
+		}
+		// Remove all segments acknowledged by the ACK and sample the last one
+		acked := -1
+		for j, segment := range outstanding[receiver] {
+			if seqAfter(segment.endSeq, tcpPacket.AckNr) {
+				break
+			}
+			acked = j
+		}
+		if acked < 0 {
+			continue
+		}
+		segment := outstanding[receiver][acked]
+		outstanding[receiver] = outstanding[receiver][acked+1:]
+		if segment.retransmitted {
+			continue
+		}
+		if receiver == 0 {
+			rtt.ServerSamples = append(rtt.ServerSamples, packet.Timestamp-segment.timestamp)
+		} else {
+			rtt.ClientSamples = append(rtt.ClientSamples, packet.Timestamp-segment.timestamp)
+		}
+	}
+	return rtt
+}
+
+// getHandshakeRTT sets the handshake RTT based on the last SYN before the SYN/ACK
+// and the first ACK of the client acknowledging the SYN/ACK.
+func (rtt *TCPRTT) getHandshakeRTT(flow *flows.TCPFlow) {
+	synIndex := -1
+	synAckIndex := -1
+	for i, packet := range flow.Packets {
+		tcpPacket := flow.TCPPacket[i]
+		switch {
+		case tcpPacket.RST:
+			return
+		case synAckIndex < 0 && tcpPacket.SYN && !tcpPacket.ACK && packet.FromClient:
+			synIndex = i
+		case synAckIndex < 0 && tcpPacket.SYN && tcpPacket.ACK && !packet.FromClient && synIndex >= 0 &&
+			tcpPacket.AckNr == flow.TCPPacket[synIndex].SeqNr+1:
+			synAckIndex = i
+			rtt.HandshakeServer = packet.Timestamp - flow.Packets[synIndex].Timestamp
+		case synAckIndex >= 0 && tcpPacket.SYN && tcpPacket.ACK && !packet.FromClient:
+			// Retransmitted SYN/ACK, the ACK of the client is ambiguous
+			return
+		case synAckIndex >= 0 && tcpPacket.ACK && !tcpPacket.SYN && packet.FromClient:
+			if tcpPacket.AckNr == flow.TCPPacket[synAckIndex].SeqNr+1 {
+				rtt.HandshakeClient = packet.Timestamp - flow.Packets[synAckIndex].Timestamp
+			}
+			return
+		}
+	}
+}
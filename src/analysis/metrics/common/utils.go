@@ -10,7 +10,7 @@ import (
 	"github.com/cespare/xxhash"
 )
 
-// ProtocolKeyType is the hashed interpretation of an application protocol (TCP/UDP + Port)
+// ProtocolKeyType is the hashed interpretation of an application protocol (TCP/UDP/SCTP/QUIC + Port, ICMP/ICMPv6 + request type)
 type ProtocolKeyType uint64
 
 func GetProtocolKey(protocolString string) ProtocolKeyType {
@@ -22,6 +22,14 @@ func GetProtocolKey(protocolString string) ProtocolKeyType {
 		protocol = flows.TCP
 	case "udp":
 		protocol = flows.UDP
+	case "quic":
+		protocol = flows.QUIC
+	case "sctp":
+		protocol = flows.SCTP
+	case "icmp":
+		protocol = flows.ICMP
+	case "icmpv6":
+		protocol = flows.ICMPv6
 	}
 
 	port, err := strconv.ParseUint(splits[1], 10, 16)
@@ -0,0 +1,26 @@
+package flows
+
+import (
+	"scalable-flow-analyzer/flows"
+)
+
+type MetricConnectionState struct{}
+
+func newMetricConnectionState() *MetricConnectionState {
+	return &MetricConnectionState{}
+}
+
+func (mcs *MetricConnectionState) onTCPFlush(flow *flows.TCPFlow) ExportableValue {
+	return ValueConnectionState{state: flow.ConnectionState()}
+}
+
+// ValueConnectionState contains the Zeek connection state (e.g. SF, S0, REJ) of a TCP flow
+type ValueConnectionState struct {
+	state flows.TCPConnectionState
+}
+
+func (vcs ValueConnectionState) export() map[string]interface{} {
+	return map[string]interface{}{
+		"connectionState": vcs.state.String(),
+	}
+}
@@ -0,0 +1,26 @@
+package flows
+
+import (
+	"scalable-flow-analyzer/flows"
+)
+
+type MetricInterface struct{}
+
+func newMetricInterface() *MetricInterface {
+	return &MetricInterface{}
+}
+
+func (mi *MetricInterface) onFlush(flow *flows.Flow) ExportableValue {
+	return ValueInterface{captureInterface: flow.Interface}
+}
+
+type ValueInterface struct {
+	// ID of the (pcapng) interface the first packet of the flow was captured on.
+	captureInterface uint32
+}
+
+func (vi ValueInterface) export() map[string]interface{} {
+	return map[string]interface{}{
+		"captureInterface": vi.captureInterface,
+	}
+}
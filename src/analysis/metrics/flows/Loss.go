@@ -0,0 +1,48 @@
+package flows
+
+import (
+	"scalable-flow-analyzer/flows"
+	"scalable-flow-analyzer/metrics/common"
+)
+
+type MetricLoss struct{}
+
+func newMetricLoss() *MetricLoss {
+	return &MetricLoss{}
+}
+
+func (ml *MetricLoss) calc(flow *flows.TCPFlow) ValueLoss {
+	loss := common.GetTCPLoss(flow)
+
+	return ValueLoss{
+		client: loss.Client,
+		server: loss.Server,
+	}
+}
+
+func (ml *MetricLoss) onTCPFlush(flow *flows.TCPFlow) ExportableValue {
+	value := ml.calc(flow)
+	return value
+}
+
+type ValueLoss struct {
+	// Retransmissions, out of order packets, duplicate ACKs and zero window probes sent by the client.
+	client common.TCPDirectionLoss
+	// Retransmissions, out of order packets, duplicate ACKs and zero window probes sent by the server.
+	server common.TCPDirectionLoss
+}
+
+func (vl ValueLoss) export() map[string]interface{} {
+	return map[string]interface{}{
+		"retransmissionsClient":    vl.client.Retransmissions,
+		"retransmissionsServer":    vl.server.Retransmissions,
+		"retransmittedBytesClient": vl.client.RetransmittedBytes,
+		"retransmittedBytesServer": vl.server.RetransmittedBytes,
+		"outOfOrderClient":         vl.client.OutOfOrder,
+		"outOfOrderServer":         vl.server.OutOfOrder,
+		"dupAcksClient":            vl.client.DupAcks,
+		"dupAcksServer":            vl.server.DupAcks,
+		"zeroWindowProbesClient":   vl.client.ZeroWindowProbes,
+		"zeroWindowProbesServer":   vl.server.ZeroWindowProbes,
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/dustin/go-humanize"
-	"os"
-	"path"
 	"time"
 )
 
@@ -16,10 +14,13 @@ type Metric struct {
 	rrIdentifier *common.ReqResIdentifier
 
 	exportChannel chan *string
+	rotateChannel chan string
 	doneChannel   chan bool
+	export        exportWriter
 
-	metrics   []registrableMetric
-	rrMetrics []registrableRRMetric
+	metrics    []registrableMetric
+	rrMetrics  []registrableRRMetric
+	tcpMetrics []registrableTCPMetric
 }
 
 type ExportableValue interface {
@@ -34,29 +35,45 @@ type registrableRRMetric interface {
 	onFlush(flow *flows.Flow, reqRes []*common.RequestResponse) ExportableValue
 }
 
-func NewMetric(samplingRate int64, computeRRPs bool, exportBufferSize uint) *Metric {
+// registrableTCPMetric are metrics, which are only computed for TCP flows
+type registrableTCPMetric interface {
+	onTCPFlush(flow *flows.TCPFlow) ExportableValue
+}
+
+func NewMetric(samplingRate int64, computeRRPs bool, exportBufferSize uint, addressExporter *common.AddressExporter) *Metric {
 	metric := &Metric{
 		computeRRPs:   computeRRPs,
 		exportChannel: make(chan *string, exportBufferSize),
+		rotateChannel: make(chan string),
 		doneChannel:   make(chan bool),
+		export:        exportWriter{format: ExportFormatJSON, compression: ExportCompressionNone},
 	}
 
 	metricFlowRate := newMetricFlowRate()
 	metricFlowRate.samplingRate = samplingRate
 
 	metric.addMetric(metricFlowRate)
-	metric.addMetric(newMetricProtocol())
+	metric.addMetric(newMetricProtocol(addressExporter))
 	metric.addMetric(newMetricFlowSize())
 	metric.addMetric(newMetricPackets())
 	metric.addMetric(newMetricFlowDuration())
+	metric.addMetric(newMetricTunnel())
+	metric.addMetric(newMetricInterface())
+	metric.addTCPMetric(newMetricRTT())
+	metric.addTCPMetric(newMetricLoss())
+	metric.addTCPMetric(newMetricConnectionState())
+
+	metricTCPOptions := newMetricTCPOptions()
+	metricTCPOptions.samplingRate = samplingRate
+	metric.addTCPMetric(metricTCPOptions)
 
 	if !computeRRPs {
 		return metric
 	}
 
 	metric.rrIdentifier = common.NewReqResIdentifier(
-		false, false,
-		nil, nil,
+		false, false, false, false,
+		nil, nil, nil,
 	)
 
 	metric.addRRMetric(newMetricRRPs())
@@ -68,6 +85,10 @@ func (m *Metric) addMetric(metric registrableMetric) {
 	m.metrics = append(m.metrics, metric)
 }
 
+func (m *Metric) addTCPMetric(tcpMetric registrableTCPMetric) {
+	m.tcpMetrics = append(m.tcpMetrics, tcpMetric)
+}
+
 func (m *Metric) addRRMetric(rrMetric registrableRRMetric) {
 	m.rrMetrics = append(m.rrMetrics, rrMetric)
 }
@@ -86,7 +107,7 @@ func (m *Metric) OnTCPFlush(flow *flows.TCPFlow) {
 		}
 	}
 
-	m.onFlush(&flow.Flow, rr)
+	m.onFlush(&flow.Flow, rr, flow)
 }
 
 // Callback that is called by the pools, once reconstruction for a flow is done.
@@ -103,13 +124,48 @@ func (m *Metric) OnUDPFlush(flow *flows.UDPFlow) {
 		}
 	}
 
-	m.onFlush(&flow.Flow, rr)
+	m.onFlush(&flow.Flow, rr, nil)
+}
+
+// Callback that is called by the pools, once an SCTP association is flushed.
+// This means that this method runs concurrently.
+func (m *Metric) OnSCTPFlush(flow *flows.SCTPFlow) {
+	var protocol = common.GetProtocol(&flow.Flow)
+	var rr = make([]*common.RequestResponse, 0)
+	var dropFlow bool
+
+	if m.computeRRPs {
+		rr, dropFlow = m.rrIdentifier.OnSCTPFlush(protocol, flow)
+		if dropFlow {
+			return
+		}
+	}
+
+	m.onFlush(&flow.Flow, rr, nil)
+}
+
+// Callback that is called by the pools, once an ICMP flow timed out.
+// This means that this method runs concurrently.
+func (m *Metric) OnICMPFlush(flow *flows.ICMPFlow) {
+	var protocol = common.GetProtocol(&flow.Flow)
+	var rr = make([]*common.RequestResponse, 0)
+	var dropFlow bool
+
+	if m.computeRRPs {
+		rr, dropFlow = m.rrIdentifier.OnICMPFlush(protocol, flow)
+		if dropFlow {
+			return
+		}
+	}
+
+	m.onFlush(&flow.Flow, rr, nil)
 }
 
 // This method is called by the callback. Simplifies metric implementation, as
-// they are not required to implement different methods for TCP/UDP.
-func (m *Metric) onFlush(flow *flows.Flow, rr []*common.RequestResponse) {
-	values := make([]ExportableValue, len(m.metrics)+len(m.rrMetrics))
+// they are not required to implement different methods for TCP/UDP/SCTP/ICMP.
+// tcpFlow is nil for other protocols.
+func (m *Metric) onFlush(flow *flows.Flow, rr []*common.RequestResponse, tcpFlow *flows.TCPFlow) {
+	values := make([]ExportableValue, len(m.metrics)+len(m.rrMetrics), len(m.metrics)+len(m.rrMetrics)+len(m.tcpMetrics))
 
 	for i, metric := range m.metrics {
 		values[i] = metric.onFlush(flow)
@@ -121,6 +177,12 @@ func (m *Metric) onFlush(flow *flows.Flow, rr []*common.RequestResponse) {
 		}
 	}
 
+	if tcpFlow != nil {
+		for _, tcpMetric := range m.tcpMetrics {
+			values = append(values, tcpMetric.onTCPFlush(tcpFlow))
+		}
+	}
+
 	combinedMetric := combineMetrics(values)
 	m.exportChannel <- serializeMetric(combinedMetric)
 }
@@ -160,68 +222,43 @@ func (m *Metric) Wait() {
 	<-m.doneChannel
 }
 
+// Rotate finishes the export files of the current rotation interval and moves them to the "directory".
+// Metrics flushed afterwards are written to new export files.
+func (m *Metric) Rotate(directory string) {
+	m.exportChannel <- nil
+	m.rotateChannel <- directory
+}
+
 // Should always be called as a goroutine. Writes serialized metrics directly to disk.
+// A nil metric in the exportChannel signals a rotation to the directory sent by Rotate.
 func (m *Metric) ExportRoutine(directory string) {
-	filename := path.Join(directory, "flow_metrics.json")
-	if _, err := os.Stat(filename); err == nil {
-		// File exists
-		err := os.Remove(filename)
-		if err != nil {
-			fmt.Println(err.Error())
-			panic("Could not remove '" + filename + "'!")
-		}
-	}
-
-	f, err := os.Create(filename)
-	if err != nil {
-		fmt.Println(err.Error())
-		panic("Could not create '" + filename + "'!")
-	}
-
-	err = os.Chmod(filename, 0644)
-	if err != nil {
-		fmt.Println(err.Error())
-		fmt.Println("Could not change permissions for '" + filename + "'!")
-	}
+	m.export.directory = directory
+	m.export.open()
 
 	fmt.Println("Export routine successfully setup.")
 	start := time.Now()
 
-	_, err = f.WriteString("{")
-	if err != nil {
-		fmt.Println(err.Error())
-		panic("Error writing to file!")
-	}
-
 	id := 0
-	serializedMetric := ""
-	for serializedMetricPointer := range m.exportChannel {
-		if len(serializedMetric) != 0 {
-			_, err = f.WriteString(fmt.Sprintf("\"%d\":%s,", id, serializedMetric))
-			if err != nil {
-				fmt.Println(err.Error())
-				panic("Error writing to file!")
-			}
+	total := 0
+	for serializedMetric := range m.exportChannel {
+		if serializedMetric == nil {
+			rotationDirectory := <-m.rotateChannel
+			m.export.close()
+			m.export.moveFiles(rotationDirectory)
+			m.export.open()
+			total += id
+			id = 0
+			continue
 		}
 
-		serializedMetric = *serializedMetricPointer
 		id++
+		m.export.write(*serializedMetric, id)
 	}
-
-	_, err = f.WriteString(fmt.Sprintf("\"%d\":%s}", id, serializedMetric))
-	if err != nil {
-		fmt.Println(err.Error())
-		panic("Error writing to file!")
-	}
-
-	err = f.Close()
-	if err != nil {
-		fmt.Println(err.Error())
-		panic("Error closing file!")
-	}
+	m.export.close()
+	total += id
 
 	fmt.Println("Finished writing json. Took:\t", time.Since(start))
-	fmt.Printf("Export successful. Exported:\t %s flow metrics", humanize.Comma(int64(id)))
+	fmt.Printf("Export successful. Exported:\t %s flow metrics", humanize.Comma(int64(total)))
 
 	m.doneChannel <- true
 	close(m.doneChannel)
@@ -2,21 +2,32 @@ package flows
 
 import (
 	"scalable-flow-analyzer/flows"
+	"scalable-flow-analyzer/metrics/common"
 )
 
-type MetricProtocol struct{}
+type MetricProtocol struct {
+	// Defines whether hashes and/or (anonymized) addresses are exported.
+	addressExporter *common.AddressExporter
+}
 
-func newMetricProtocol() *MetricProtocol {
-	return &MetricProtocol{}
+func newMetricProtocol(addressExporter *common.AddressExporter) *MetricProtocol {
+	return &MetricProtocol{addressExporter: addressExporter}
 }
 
 func (mp *MetricProtocol) onFlush(flow *flows.Flow) ExportableValue {
 	value := ValueProtocol{
-		protocol:      flows.GetProtocolString(flow.Protocol),
-		portClient:    flow.ClientPort,
-		portServer:    flow.ServerPort,
-		addressClient: int64(flow.ClientAddr),
-		addressServer: int64(flow.ServerAddr),
+		protocol:        flows.GetProtocolString(flow.Protocol),
+		portClient:      flow.ClientPort,
+		portServer:      flow.ServerPort,
+		addressClient:   int64(flow.ClientAddr),
+		addressServer:   int64(flow.ServerAddr),
+		exportHash:      mp.addressExporter.ExportsHashes(),
+		exportIPAddress: mp.addressExporter.ExportsAddresses(),
+	}
+
+	if value.exportIPAddress {
+		value.ipAddressClient = mp.addressExporter.FormatAddress(flow.ClientIPAddress)
+		value.ipAddressServer = mp.addressExporter.FormatAddress(flow.ServerIPAddress)
 	}
 
 	return value
@@ -33,14 +44,29 @@ type ValueProtocol struct {
 	addressClient int64
 	// Address the server used. Conversion to int64 needed for elasticsearch.
 	addressServer int64
+	// Whether the hashed addresses above are exported.
+	exportHash bool
+	// Whether the IP addresses below are exported.
+	exportIPAddress bool
+	// IP address of the client as string.
+	ipAddressClient string
+	// IP address of the server as string.
+	ipAddressServer string
 }
 
 func (vp ValueProtocol) export() map[string]interface{} {
-	return map[string]interface{}{
-		"protocol":      vp.protocol,
-		"portClient":    vp.portClient,
-		"portServer":    vp.portServer,
-		"addressClient": vp.addressClient,
-		"addressServer": vp.addressServer,
+	export := map[string]interface{}{
+		"protocol":   vp.protocol,
+		"portClient": vp.portClient,
+		"portServer": vp.portServer,
+	}
+	if vp.exportHash {
+		export["addressClient"] = vp.addressClient
+		export["addressServer"] = vp.addressServer
+	}
+	if vp.exportIPAddress {
+		export["ipAddressClient"] = vp.ipAddressClient
+		export["ipAddressServer"] = vp.ipAddressServer
 	}
+	return export
 }
@@ -12,7 +12,7 @@ func newMetricRRPs() *MetricRRPs {
 }
 
 func (mr *MetricRRPs) calc(flow *flows.Flow, reqRes []*common.RequestResponse) ValueRRPairs {
-	var rrps = make([][2]uint16, 0)
+	var rrps = make([][2]uint32, 0)
 
 	for _, rr := range reqRes {
 		requests := rr.Requests
@@ -27,7 +27,7 @@ func (mr *MetricRRPs) calc(flow *flows.Flow, reqRes []*common.RequestResponse) V
 		}(len(requests), len(responses))
 
 		for i := 0; i < lastCommonIndex; i++ {
-			rrps = append(rrps, [2]uint16{requests[i].LengthPayload, responses[i].LengthPayload})
+			rrps = append(rrps, [2]uint32{requests[i].LengthPayload, responses[i].LengthPayload})
 		}
 	}
 
@@ -43,7 +43,7 @@ func (mr *MetricRRPs) onFlush(flow *flows.Flow, reqRes []*common.RequestResponse
 
 type ValueRRPairs struct {
 	// The request response pairs for a flow.
-	rrps [][2]uint16
+	rrps [][2]uint32
 }
 
 func (vr ValueRRPairs) export() map[string]interface{} {
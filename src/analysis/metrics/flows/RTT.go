@@ -0,0 +1,68 @@
+package flows
+
+import (
+	"scalable-flow-analyzer/flows"
+	"scalable-flow-analyzer/metrics/common"
+)
+
+type MetricRTT struct{}
+
+func newMetricRTT() *MetricRTT {
+	return &MetricRTT{}
+}
+
+func (mr *MetricRTT) calc(flow *flows.TCPFlow) ValueRTT {
+	rtt := common.GetTCPRTT(flow)
+
+	return ValueRTT{
+		handshake:        rtt.Handshake(),
+		handshakeClient:  rtt.HandshakeClient,
+		handshakeServer:  rtt.HandshakeServer,
+		minClient:        rtt.MinClient(),
+		minServer:        rtt.MinServer(),
+		meanClient:       common.MeanRTT(rtt.ClientSamples),
+		meanServer:       common.MeanRTT(rtt.ServerSamples),
+		numSamplesClient: len(rtt.ClientSamples),
+		numSamplesServer: len(rtt.ServerSamples),
+	}
+}
+
+func (mr *MetricRTT) onTCPFlush(flow *flows.TCPFlow) ExportableValue {
+	value := mr.calc(flow)
+	return value
+}
+
+// ValueRTT contains the round-trip times of a TCP flow in nano seconds, measured at the capture point.
+// Client-side RTTs are between capture point and client, server-side RTTs between capture point and server.
+// Missing values are -1.
+type ValueRTT struct {
+	// End-to-end RTT of the handshake.
+	handshake int64
+	// Client-side RTT of the handshake (SYN/ACK -> ACK).
+	handshakeClient int64
+	// Server-side RTT of the handshake (SYN -> SYN/ACK).
+	handshakeServer int64
+	// Minimal RTT of the handshake and the data segment samples.
+	minClient int64
+	minServer int64
+	// Mean RTT of the data segment samples.
+	meanClient int64
+	meanServer int64
+	// Number of data segment samples.
+	numSamplesClient int
+	numSamplesServer int
+}
+
+func (vr ValueRTT) export() map[string]interface{} {
+	return map[string]interface{}{
+		"rttHandshake":       vr.handshake,
+		"rttHandshakeClient": vr.handshakeClient,
+		"rttHandshakeServer": vr.handshakeServer,
+		"rttMinClient":       vr.minClient,
+		"rttMinServer":       vr.minServer,
+		"rttMeanClient":      vr.meanClient,
+		"rttMeanServer":      vr.meanServer,
+		"rttSamplesClient":   vr.numSamplesClient,
+		"rttSamplesServer":   vr.numSamplesServer,
+	}
+}
@@ -0,0 +1,137 @@
+package flows
+
+import (
+	"scalable-flow-analyzer/flows"
+	"time"
+)
+
+type MetricTCPOptions struct {
+	// Sampling rate of the window evolution in milliseconds. If equal to zero,
+	// every change of the advertised window is recorded.
+	samplingRate int64
+}
+
+func newMetricTCPOptions() *MetricTCPOptions {
+	return &MetricTCPOptions{}
+}
+
+// getOptionValues returns the MSS and the window scale of the options, -1 if the option or the SYN is missing
+func getOptionValues(options flows.TCPOptions, syn bool) (mss, windowScale int) {
+	mss, windowScale = -1, -1
+	if !syn {
+		return mss, windowScale
+	}
+	if options.MSS > 0 {
+		mss = int(options.MSS)
+	}
+	if options.HasWindowScale {
+		windowScale = int(options.WindowScale)
+	}
+	return mss, windowScale
+}
+
+// calcWindows returns the scaled windows advertised by the client and the server.
+// If samplingRate is set, the last window of each sampling interval is returned, otherwise all changes.
+func (mto *MetricTCPOptions) calcWindows(flow *flows.TCPFlow) (windowsClient, windowsServer []uint32) {
+	windowsClient, windowsServer = []uint32{}, []uint32{}
+	sampleTimespan := time.Millisecond.Nanoseconds() * mto.samplingRate
+	var lastWindow [2]uint32
+	var windowSeen [2]bool
+	start := flow.Packets[0].Timestamp
+	nextSampleStart := start + sampleTimespan
+	for i, packet := range flow.Packets {
+		if flow.TCPPacket[i].RST {
+			continue
+		}
+		for sampleTimespan > 0 && packet.Timestamp >= nextSampleStart {
+			// Close the sampling interval, keeping the window of the previous one if no packet was sent
+			nextSampleStart += sampleTimespan
+			if windowSeen[0] {
+				windowsClient = append(windowsClient, lastWindow[0])
+			}
+			if windowSeen[1] {
+				windowsServer = append(windowsServer, lastWindow[1])
+			}
+		}
+
+		direction := 0
+		if !packet.FromClient {
+			direction = 1
+		}
+		window := flow.ScaledWindow(i)
+		if sampleTimespan == 0 && (!windowSeen[direction] || window != lastWindow[direction]) {
+			if packet.FromClient {
+				windowsClient = append(windowsClient, window)
+			} else {
+				windowsServer = append(windowsServer, window)
+			}
+		}
+		lastWindow[direction] = window
+		windowSeen[direction] = true
+	}
+
+	if sampleTimespan > 0 {
+		if windowSeen[0] {
+			windowsClient = append(windowsClient, lastWindow[0])
+		}
+		if windowSeen[1] {
+			windowsServer = append(windowsServer, lastWindow[1])
+		}
+	}
+	return windowsClient, windowsServer
+}
+
+func (mto *MetricTCPOptions) calc(flow *flows.TCPFlow) ValueTCPOptions {
+	value := ValueTCPOptions{
+		synClient:           flow.ClientSYN,
+		synServer:           flow.ServerSYN,
+		sackPermittedClient: flow.ClientSYN && flow.ClientOptions.SACKPermitted,
+		sackPermittedServer: flow.ServerSYN && flow.ServerOptions.SACKPermitted,
+		timestampsClient:    flow.ClientSYN && flow.ClientOptions.Timestamps,
+		timestampsServer:    flow.ServerSYN && flow.ServerOptions.Timestamps,
+	}
+	value.mssClient, value.windowScaleClient = getOptionValues(flow.ClientOptions, flow.ClientSYN)
+	value.mssServer, value.windowScaleServer = getOptionValues(flow.ServerOptions, flow.ServerSYN)
+	value.windowsClient, value.windowsServer = mto.calcWindows(flow)
+	return value
+}
+
+func (mto *MetricTCPOptions) onTCPFlush(flow *flows.TCPFlow) ExportableValue {
+	value := mto.calc(flow)
+	return value
+}
+
+// ValueTCPOptions contains the options of the handshake and the receive windows of a TCP flow.
+// The options are only valid, if the SYN of the direction was observed. Missing MSS and window scale options are -1.
+type ValueTCPOptions struct {
+	synClient           bool
+	synServer           bool
+	mssClient           int
+	mssServer           int
+	windowScaleClient   int
+	windowScaleServer   int
+	sackPermittedClient bool
+	sackPermittedServer bool
+	timestampsClient    bool
+	timestampsServer    bool
+	// Scaled receive windows in bytes advertised by the client and the server.
+	windowsClient []uint32
+	windowsServer []uint32
+}
+
+func (vto ValueTCPOptions) export() map[string]interface{} {
+	return map[string]interface{}{
+		"synClient":           vto.synClient,
+		"synServer":           vto.synServer,
+		"mssClient":           vto.mssClient,
+		"mssServer":           vto.mssServer,
+		"windowScaleClient":   vto.windowScaleClient,
+		"windowScaleServer":   vto.windowScaleServer,
+		"sackPermittedClient": vto.sackPermittedClient,
+		"sackPermittedServer": vto.sackPermittedServer,
+		"timestampsClient":    vto.timestampsClient,
+		"timestampsServer":    vto.timestampsServer,
+		"windowsClient":       vto.windowsClient,
+		"windowsServer":       vto.windowsServer,
+	}
+}
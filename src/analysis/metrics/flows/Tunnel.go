@@ -0,0 +1,35 @@
+package flows
+
+import (
+	"scalable-flow-analyzer/flows"
+)
+
+type MetricTunnel struct{}
+
+func newMetricTunnel() *MetricTunnel {
+	return &MetricTunnel{}
+}
+
+func (mt *MetricTunnel) onFlush(flow *flows.Flow) ExportableValue {
+	return ValueTunnel{
+		tunnelType: flow.TunnelType,
+		tunnelID:   flow.TunnelID,
+	}
+}
+
+type ValueTunnel struct {
+	// The outermost tunnel the flow was encapsulated in (flows.TunnelNone if not tunneled).
+	tunnelType uint8
+	// ID of the tunnel (VNI, TEID, MPLS label or GRE key).
+	tunnelID uint32
+}
+
+func (vt ValueTunnel) export() map[string]interface{} {
+	if vt.tunnelType == flows.TunnelNone {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"tunnelType": flows.GetTunnelTypeString(vt.tunnelType),
+		"tunnelID":   vt.tunnelID,
+	}
+}
@@ -0,0 +1,208 @@
+package flows
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+
+	"github.com/klauspost/compress/zstd"
+	gzip "github.com/klauspost/pgzip"
+)
+
+// Formats of the flow export
+const (
+	ExportFormatJSON   = "json"   // One JSON object per file, which maps the flow ID to the flow metrics
+	ExportFormatNDJSON = "ndjson" // Newline-delimited JSON, one flow per line. Can be parsed incrementally.
+)
+
+// Compressions of the flow export
+const (
+	ExportCompressionNone = "none"
+	ExportCompressionGzip = "gzip"
+	ExportCompressionZstd = "zstd"
+)
+
+const exportFilePrefix = "flow_metrics"
+
+// countingWriter counts the bytes written to the file, i.e. after compression
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+// exportWriter writes the serialized flow metrics to the export files.
+// It is only used by the ExportRoutine.
+// If maxFileSize is set, a new file is started, once the file exceeds the size.
+type exportWriter struct {
+	directory   string
+	format      string
+	compression string
+	maxFileSize int64
+
+	file       *os.File
+	counter    *countingWriter
+	compressor io.WriteCloser // nil, if not compressed
+	writer     *bufio.Writer
+
+	part      int      // Number of the current file within the rotation interval
+	flows     int      // Number of flows in the current file
+	filenames []string // All files of the current rotation interval
+}
+
+// getFilename returns the name of the export file with the number part
+func (e *exportWriter) getFilename(part int) string {
+	filename := exportFilePrefix
+	if e.maxFileSize > 0 {
+		filename += fmt.Sprintf("_%04d", part)
+	}
+	filename += "." + e.format
+	switch e.compression {
+	case ExportCompressionGzip:
+		filename += ".gz"
+	case ExportCompressionZstd:
+		filename += ".zst"
+	}
+	return path.Join(e.directory, filename)
+}
+
+// open creates (or replaces) the next export file
+func (e *exportWriter) open() {
+	filename := e.getFilename(e.part)
+	if _, err := os.Stat(filename); err == nil {
+		// File exists
+		err := os.Remove(filename)
+		if err != nil {
+			fmt.Println(err.Error())
+			panic("Could not remove '" + filename + "'!")
+		}
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("Could not create '" + filename + "'!")
+	}
+
+	err = os.Chmod(filename, 0644)
+	if err != nil {
+		fmt.Println(err.Error())
+		fmt.Println("Could not change permissions for '" + filename + "'!")
+	}
+
+	e.file = f
+	e.counter = &countingWriter{writer: f}
+	var output io.Writer = e.counter
+	switch e.compression {
+	case ExportCompressionGzip:
+		e.compressor = gzip.NewWriter(e.counter)
+		output = e.compressor
+	case ExportCompressionZstd:
+		e.compressor, err = zstd.NewWriter(e.counter, zstd.WithZeroFrames(true))
+		if err != nil {
+			fmt.Println(err.Error())
+			panic("Could not create zstd encoder!")
+		}
+		output = e.compressor
+	default:
+		e.compressor = nil
+	}
+	e.writer = bufio.NewWriter(output)
+	e.filenames = append(e.filenames, filename)
+	e.flows = 0
+
+	if e.format == ExportFormatJSON {
+		e.writeString("{")
+	}
+}
+
+// write appends the serialized metric of a flow to the export file
+func (e *exportWriter) write(serializedMetric string, id int) {
+	if e.format == ExportFormatNDJSON {
+		e.writeString(serializedMetric)
+		e.writeString("\n")
+	} else {
+		if e.flows > 0 {
+			e.writeString(",")
+		}
+		e.writeString(fmt.Sprintf("\"%d\":%s", id, serializedMetric))
+	}
+	e.flows++
+
+	if e.maxFileSize > 0 && e.counter.written >= e.maxFileSize {
+		e.close()
+		e.part++
+		e.open()
+	}
+}
+
+func (e *exportWriter) writeString(s string) {
+	_, err := e.writer.WriteString(s)
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("Error writing to file!")
+	}
+}
+
+// close finishes the current export file
+func (e *exportWriter) close() {
+	if e.format == ExportFormatJSON {
+		e.writeString("}")
+	}
+	err := e.writer.Flush()
+	if err == nil && e.compressor != nil {
+		err = e.compressor.Close()
+	}
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("Error writing to file!")
+	}
+
+	err = e.file.Close()
+	if err != nil {
+		fmt.Println(err.Error())
+		panic("Error closing file!")
+	}
+}
+
+// moveFiles moves all (closed) files of the current rotation interval to the directory
+func (e *exportWriter) moveFiles(directory string) {
+	for _, filename := range e.filenames {
+		err := os.Rename(filename, path.Join(directory, path.Base(filename)))
+		if err != nil {
+			fmt.Println(err.Error())
+			panic("Could not move '" + filename + "' to '" + directory + "'!")
+		}
+	}
+	e.filenames = nil
+	e.part = 0
+}
+
+// SetExportFormat sets the format (json or ndjson) and the compression (none, gzip or zstd) of the export files.
+// If maxFileSize is greater than 0, the export is split into numbered files of about this size (after compression).
+// Must be called before the ExportRoutine is started.
+func (m *Metric) SetExportFormat(format, compression string, maxFileSize int64) {
+	if format != ExportFormatJSON && format != ExportFormatNDJSON {
+		log.Fatalln("Unknown flow export format:", format)
+	}
+	if compression != ExportCompressionNone && compression != ExportCompressionGzip && compression != ExportCompressionZstd {
+		log.Fatalln("Unknown flow export compression:", compression)
+	}
+	m.export.format = format
+	m.export.compression = compression
+	m.export.maxFileSize = maxFileSize
+}
+
+// MoveExportFiles moves the export files of the last rotation interval to the directory.
+// Must be called after Wait.
+func (m *Metric) MoveExportFiles(directory string) {
+	m.export.moveFiles(directory)
+}
@@ -38,6 +38,7 @@ type ClusterController struct {
 	useClusters        bool
 	collectClusterInfo bool
 	infoFilesPath      string
+	addressExporter    *common.AddressExporter
 }
 
 type Model struct {
@@ -68,17 +69,18 @@ type UsersInfos struct {
 // DefaultClusterIndex is used whenever a metric does not support clustering, or no clustering is used
 const DefaultClusterIndex = 0
 
-func NewClusterController(metric *Metric, infoPath, modelPath string) *ClusterController {
+func NewClusterController(metric *Metric, infoPath, modelPath string, addressExporter *common.AddressExporter) *ClusterController {
 	cc := &ClusterController{
-		metric:       metric,
-		rrpModel:     make(map[common.ProtocolKeyType]Model),
-		rrpsInfo:     make(map[common.ProtocolKeyType]*RRPsInfos),
-		flowModel:    make(map[common.ProtocolKeyType]Model),
-		flowsInfo:    make(map[common.ProtocolKeyType]*FlowsInfos),
-		sessionModel: make(map[common.ProtocolKeyType]Model),
-		sessionsInfo: make(map[common.ProtocolKeyType]*SessionsInfos),
-		userModel:    make(map[common.ProtocolKeyType]Model),
-		usersInfo:    make(map[common.ProtocolKeyType]*UsersInfos),
+		metric:          metric,
+		rrpModel:        make(map[common.ProtocolKeyType]Model),
+		rrpsInfo:        make(map[common.ProtocolKeyType]*RRPsInfos),
+		flowModel:       make(map[common.ProtocolKeyType]Model),
+		flowsInfo:       make(map[common.ProtocolKeyType]*FlowsInfos),
+		sessionModel:    make(map[common.ProtocolKeyType]Model),
+		sessionsInfo:    make(map[common.ProtocolKeyType]*SessionsInfos),
+		userModel:       make(map[common.ProtocolKeyType]Model),
+		usersInfo:       make(map[common.ProtocolKeyType]*UsersInfos),
+		addressExporter: addressExporter,
 	}
 	switch infoPath {
 	case "":
@@ -287,13 +289,14 @@ func (cc *ClusterController) CollectAndSetFlowClusterIndex(flow *flows.Flow, req
 }
 
 // Returns the index of the cluster to which the session belongs
-func (cc *ClusterController) CollectAndSetSessionClusterIndex(session *session, clientAddress uint64, protocol *common.Protocol) {
+func (cc *ClusterController) CollectAndSetSessionClusterIndex(session *session, clientAddress uint64, clientIPAddress flows.IPAddress,
+	protocol *common.Protocol) {
 	if !cc.useClusters && !cc.collectClusterInfo {
 		session.sessionClusterIndex = DefaultClusterIndex
 		return
 	}
 	protocolKey := protocol.ProtocolKey
-	sessionInfos := cc.getSessionInfo(session, clientAddress)
+	sessionInfos := cc.getSessionInfo(session, clientAddress, clientIPAddress)
 
 	// Predict
 	if !cc.useClusters {
@@ -467,8 +470,9 @@ func (cc *ClusterController) getFlowInfo(flow *flows.Flow, reqRes []*common.Requ
 	interReq := cc.metric.MetricInterRequest.calc(reqRes)
 	interReqMean, interReqMin, interReqMax, interReqStdDev := utils.GetDistributionStats(getUnivariateOfBivariate(interReq))
 	flowInfo := &dataformat.Flow{
-		ServerAddress: flow.ServerAddr,
-		NumRrp:        int64(len(reqRes)),
+		ServerAddress:   cc.addressExporter.FormatHash(flow.ServerAddr),
+		ServerIpAddress: cc.addressExporter.FormatAddress(flow.ServerIPAddress),
+		NumRrp:          int64(len(reqRes)),
 		InterReq: &dataformat.Distribution{Mean: interReqMean,
 			Min:    int64(interReqMin),
 			Max:    int64(interReqMax),
@@ -479,15 +483,16 @@ func (cc *ClusterController) getFlowInfo(flow *flows.Flow, reqRes []*common.Requ
 }
 
 // Returns the session Info for a session
-func (cc *ClusterController) getSessionInfo(session *session, clientAddress uint64) *dataformat.Session {
+func (cc *ClusterController) getSessionInfo(session *session, clientAddress uint64, clientIPAddress flows.IPAddress) *dataformat.Session {
 	numServers := cc.metric.MetricNumServers.calc(session)
 	numFlows := cc.metric.MetricNumFlows.calc(session)
 	interFlowTimes := cc.metric.MetricInterFlowTimes.calc(session)
 	interFlowTimesMean, interFlowTimesMin, interFlowTimesMax, interFlowTimesStdDev := utils.GetDistributionStats(interFlowTimes)
 	sessionInfo := &dataformat.Session{
-		ClientAddress: clientAddress,
-		NumServers:    int64(numServers),
-		NumFlows:      int64(numFlows),
+		ClientAddress:   cc.addressExporter.FormatHash(clientAddress),
+		ClientIpAddress: cc.addressExporter.FormatAddress(clientIPAddress),
+		NumServers:      int64(numServers),
+		NumFlows:        int64(numFlows),
 		InterFlow: &dataformat.Distribution{Mean: interFlowTimesMean,
 			Min:    int64(interFlowTimesMin),
 			Max:    int64(interFlowTimesMax),
@@ -502,8 +507,9 @@ func (cc *ClusterController) getUserInfo(sessions *userSessionsStruct, clientAdd
 	interSession := cc.metric.MetricInterSessions.calc(sessions)
 	interSessionMean, interSessionMin, interSessionMax, interSessionStdDev := utils.GetDistributionStats(interSession)
 	userInfo := &dataformat.User{
-		ClientAddress: clientAddress,
-		NumSessions:   int64(len(sessions.sessions)),
+		ClientAddress:   cc.addressExporter.FormatHash(clientAddress),
+		ClientIpAddress: cc.addressExporter.FormatAddress(sessions.clientIPAddress),
+		NumSessions:     int64(len(sessions.sessions)),
 		InterSession: &dataformat.Distribution{Mean: interSessionMean,
 			Min:    int64(interSessionMin),
 			Max:    int64(interSessionMax),
@@ -0,0 +1,81 @@
+package standard
+
+import (
+	"scalable-flow-analyzer/flows"
+	"scalable-flow-analyzer/metrics/common"
+	"fmt"
+)
+
+// MetricConnectionState counts the TCP flows per Zeek connection state (e.g. SF, S0, REJ).
+// Each state is exported as a separate protocol metric, e.g. ConnectionStateSF.
+type MetricConnectionState struct {
+	states [flows.NumTCPConnectionStates]common.IntMetric
+}
+
+func newMetricConnectionState() *MetricConnectionState {
+	var metricConnectionState = MetricConnectionState{}
+	for i := range metricConnectionState.states {
+		metricConnectionState.states[i] = common.NewIntMetric()
+	}
+	return &metricConnectionState
+}
+
+type MetricConnectionStateCount struct {
+	metricConnectionState *MetricConnectionState
+	state                 flows.TCPConnectionState
+}
+
+// GetStates returns the metrics of all states
+func (mcs *MetricConnectionState) GetStates() []MetricProtocolExport {
+	var states []MetricProtocolExport
+	for i := range mcs.states {
+		states = append(states, &MetricConnectionStateCount{metricConnectionState: mcs, state: flows.TCPConnectionState(i)})
+	}
+	return states
+}
+
+// Export returns the metric data per Protocol
+func (mcsc *MetricConnectionStateCount) Export(protocolKey common.ProtocolKeyType) int {
+	return mcsc.metricConnectionState.states[mcsc.state].Export(protocolKey)
+}
+
+// Export the stored protocols
+func (mcsc *MetricConnectionStateCount) GetProtocols() []common.Protocol {
+	return mcsc.metricConnectionState.states[mcsc.state].GetProtocols()
+}
+
+// Name of the Metric
+func (mcsc *MetricConnectionStateCount) Name() string {
+	return "ConnectionState" + mcsc.state.String()
+}
+
+// Reset removes all values of the state
+func (mcsc *MetricConnectionStateCount) Reset() {
+	mcsc.metricConnectionState.states[mcsc.state].Reset()
+}
+
+func (mcs *MetricConnectionState) OnTCPFlush(flow *flows.TCPFlow) {
+	protocol := common.GetProtocol(&(flow.Flow))
+	mcs.states[flow.ConnectionState()].AddValue(protocol, 1)
+}
+
+// OnUDPFlush is ignored, since the connection state is only defined for TCP
+func (mcs *MetricConnectionState) OnUDPFlush(flow *flows.UDPFlow) {}
+
+// OnSCTPFlush is ignored, since the connection state is only defined for TCP
+func (mcs *MetricConnectionState) OnSCTPFlush(flow *flows.SCTPFlow) {}
+
+// OnICMPFlush is ignored, since the connection state is only defined for TCP
+func (mcs *MetricConnectionState) OnICMPFlush(flow *flows.ICMPFlow) {}
+
+// PrintStatistic prints some statistic to the console
+func (mcs *MetricConnectionState) PrintStatistic(verbose bool) {
+	for i := range mcs.states {
+		// Skip states without flows
+		if len(mcs.states[i].GetProtocols()) == 0 {
+			continue
+		}
+		fmt.Println("Metric Connection State " + flows.TCPConnectionState(i).String() + ":")
+		fmt.Print(mcs.states[i].GetStatistics(verbose))
+	}
+}
@@ -40,6 +40,11 @@ func (mcd *MetricFlowClusterDistribution) Name() string {
 	return "FlowClusterDistribution"
 }
 
+// Reset removes all values of the metric
+func (mcd *MetricFlowClusterDistribution) Reset() {
+	mcd.clusterDistribution.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mcd *MetricFlowClusterDistribution) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Flow Cluster distribution:")
@@ -49,6 +49,14 @@ func (mfr *MetricFlowRate) OnUDPFlush(flow *flows.UDPFlow) {
 	mfr.onFlush(&(flow.Flow))
 }
 
+func (mfr *MetricFlowRate) OnSCTPFlush(flow *flows.SCTPFlow) {
+	mfr.onFlush(&(flow.Flow))
+}
+
+func (mfr *MetricFlowRate) OnICMPFlush(flow *flows.ICMPFlow) {
+	mfr.onFlush(&(flow.Flow))
+}
+
 func (mfr *MetricFlowRate) onFlush(flow *flows.Flow) {
 	flowRates := mfr.calc(flow)
 	if len(flowRates) > 0 {
@@ -71,6 +79,11 @@ func (mfr *MetricFlowRate) Name() string {
 	return "FlowRate"
 }
 
+// Reset removes all values of the metric
+func (mfr *MetricFlowRate) Reset() {
+	mfr.flowRates.Reset()
+}
+
 func (mfr *MetricFlowRate) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Flow rates:")
 	fmt.Print(mfr.flowRates.GetStatistics(verbose))
@@ -47,6 +47,11 @@ func (mif *MetricInterFlow) Name() string {
 	return "InterFlowTimes"
 }
 
+// Reset removes all values of the metric
+func (mif *MetricInterFlow) Reset() {
+	mif.interFlowTimes.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mif *MetricInterFlow) PrintStatistic(verbose bool) {
 	fmt.Println("Metric InterFlowTimes:")
@@ -48,6 +48,11 @@ func (mir *MetricInterRequests) Name() string {
 	return "InterRequestTimes"
 }
 
+// Reset removes all values of the metric
+func (mir *MetricInterRequests) Reset() {
+	mir.interRequestTimes.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mir *MetricInterRequests) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Interrequest times:")
@@ -45,6 +45,11 @@ func (mis *MetricInterSessions) Name() string {
 	return "InterSessionTimes"
 }
 
+// Reset removes all values of the metric
+func (mis *MetricInterSessions) Reset() {
+	mis.interSessions.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mis *MetricInterSessions) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Inter Session times:")
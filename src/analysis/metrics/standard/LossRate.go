@@ -0,0 +1,61 @@
+package standard
+
+import (
+	"scalable-flow-analyzer/flows"
+	"scalable-flow-analyzer/metrics/common"
+	"fmt"
+)
+
+// MetricLossRate measures the loss rate of TCP flows as retransmissions per thousand data packets.
+// Flows without data packets are ignored.
+type MetricLossRate struct {
+	lossRate common.IntMetricUnivariate
+}
+
+func newMetricLossRate() *MetricLossRate {
+	var metricLossRate = MetricLossRate{}
+	metricLossRate.lossRate = common.NewIntMetricUnivariate(1, false)
+	return &metricLossRate
+}
+
+// Export returns the metric data per Protocol
+func (mlr *MetricLossRate) Export(protocolKey common.ProtocolKeyType) *common.ExportUnivariateFormat {
+	return mlr.lossRate.Export(protocolKey, DefaultClusterIndex)
+}
+
+// Export the stored protocols
+func (mlr *MetricLossRate) GetProtocols() []common.Protocol {
+	return mlr.lossRate.GetProtocols()
+}
+
+// Name of the Metric
+func (mlr *MetricLossRate) Name() string {
+	return "LossRate"
+}
+
+// Reset removes all values of the metric
+func (mlr *MetricLossRate) Reset() {
+	mlr.lossRate.Reset()
+}
+
+func (mlr *MetricLossRate) OnTCPFlush(flow *flows.TCPFlow) {
+	loss := common.GetTCPLoss(flow)
+	if lossRate := loss.LossRate(); lossRate >= 0 {
+		mlr.lossRate.AddValue(common.GetProtocol(&(flow.Flow)), DefaultClusterIndex, lossRate)
+	}
+}
+
+// OnUDPFlush is ignored, since the loss rate is only measured for TCP
+func (mlr *MetricLossRate) OnUDPFlush(flow *flows.UDPFlow) {}
+
+// OnSCTPFlush is ignored, since the loss rate is only measured for TCP
+func (mlr *MetricLossRate) OnSCTPFlush(flow *flows.SCTPFlow) {}
+
+// OnICMPFlush is ignored, since the loss rate is only measured for TCP
+func (mlr *MetricLossRate) OnICMPFlush(flow *flows.ICMPFlow) {}
+
+// PrintStatistic prints some statistic to the console
+func (mlr *MetricLossRate) PrintStatistic(verbose bool) {
+	fmt.Println("Metric Loss Rate:")
+	fmt.Print(mlr.lossRate.GetStatistics(verbose))
+}
@@ -24,6 +24,9 @@ type Metric struct {
 	MetricInterSessions              *MetricInterSessions
 	MetricNumFlows                   *MetricNumFlows
 	MetricFlowRate                   *MetricFlowRate
+	MetricRTT                        *MetricRTT
+	MetricLossRate                   *MetricLossRate
+	MetricConnectionState            *MetricConnectionState
 	MetricInterFlowTimes             *MetricInterFlow
 	MetricNumPackets                 *MetricNumPackets
 	MetricNumServers                 *MetricNumServers
@@ -47,6 +50,8 @@ type Metric struct {
 type FlowMetric interface {
 	OnTCPFlush(flow *flows.TCPFlow)
 	OnUDPFlush(flow *flows.UDPFlow)
+	OnSCTPFlush(flow *flows.SCTPFlow)
+	OnICMPFlush(flow *flows.ICMPFlow)
 	PrintStatistic(verbose bool)
 }
 
@@ -66,10 +71,14 @@ type SessionMetric interface {
 // NewMetric creates a new Metric and registers all session and request/response metrics
 // If infoPath is not empty, flow and session information will be stored to this directory
 // if clusterModelDirectory is not empty, a clustering will be used.
+// addressExporter defines how addresses are written to the info files.
+// If excludeTCPRetransmissions is set, retransmitted bytes are not part of the request and response sizes.
+// If segmentTCPBySequence is set, TCP requests and responses are identified by sequence numbers instead of the capture order.
 func NewMetric(sessionTimeout int64, infoPath, clusterModelDirectory string,
-	dropUnidirectionalFlows, reconstructTCPResponse, statisticTCPReconstruction bool) *Metric {
+	dropUnidirectionalFlows, reconstructTCPResponse, statisticTCPReconstruction, excludeTCPRetransmissions, segmentTCPBySequence bool,
+	addressExporter *common.AddressExporter) *Metric {
 	var metric = &Metric{}
-	metric.clusterController = NewClusterController(metric, infoPath, clusterModelDirectory)
+	metric.clusterController = NewClusterController(metric, infoPath, clusterModelDirectory, addressExporter)
 
 	// Session and Request/Response Identifier
 	metric.SessionIdentifier = newSessionIdentifier(sessionTimeout, metric.clusterController)
@@ -77,6 +86,7 @@ func NewMetric(sessionTimeout int64, infoPath, clusterModelDirectory string,
 
 	var reconstructionMetricSpeed *common.MetricReconstructedPacketsSpeed
 	var reconstructionMetricSize *common.MetricReconstructedPacketsSize
+	var segmentationMetric *common.MetricSegmentationComparison
 	if statisticTCPReconstruction {
 		// The reconstruction metric is automatically hooked at request response metric.
 		// We only must add it to the export
@@ -86,9 +96,12 @@ func NewMetric(sessionTimeout int64, infoPath, clusterModelDirectory string,
 		// We only must add it to the export
 		reconstructionMetricSize = common.NewMetricReconstructedPacketsSize()
 		metric.allExportedMetricsUnivariate = append(metric.allExportedMetricsUnivariate, reconstructionMetricSize)
+		// Compares the segmentation by direction changes with the segmentation by sequence numbers
+		segmentationMetric = common.NewMetricSegmentationComparison()
+		metric.allExportedMetricsUnivariate = append(metric.allExportedMetricsUnivariate, segmentationMetric)
 	}
-	metric.ReqResIdentifier = common.NewReqResIdentifier(dropUnidirectionalFlows, reconstructTCPResponse,
-		reconstructionMetricSpeed, reconstructionMetricSize)
+	metric.ReqResIdentifier = common.NewReqResIdentifier(dropUnidirectionalFlows, reconstructTCPResponse, excludeTCPRetransmissions,
+		segmentTCPBySequence, reconstructionMetricSpeed, reconstructionMetricSize, segmentationMetric)
 
 	// Flow Metrics
 	metric.MetricNumPackets = newMetricNumPackets()
@@ -99,6 +112,19 @@ func NewMetric(sessionTimeout int64, infoPath, clusterModelDirectory string,
 	metric.registerFlowMetric(metric.MetricFlowRate)
 	metric.allExportedMetricsUnivariate = append(metric.allExportedMetricsUnivariate, metric.MetricFlowRate)
 
+	metric.MetricRTT = newMetricRTT()
+	metric.registerFlowMetric(metric.MetricRTT)
+	metric.allExportedMetricsUnivariate = append(metric.allExportedMetricsUnivariate,
+		metric.MetricRTT.GetHandshake(), metric.MetricRTT.GetClient(), metric.MetricRTT.GetServer())
+
+	metric.MetricLossRate = newMetricLossRate()
+	metric.registerFlowMetric(metric.MetricLossRate)
+	metric.allExportedMetricsUnivariate = append(metric.allExportedMetricsUnivariate, metric.MetricLossRate)
+
+	metric.MetricConnectionState = newMetricConnectionState()
+	metric.registerFlowMetric(metric.MetricConnectionState)
+	metric.allExportedMetrics = append(metric.allExportedMetrics, metric.MetricConnectionState.GetStates()...)
+
 	// RequestResponse Metrics
 	metric.MetricRRPClusterDistribution = newMetricRRPClusterDistribution()
 	metric.registerRRMetric(metric.MetricRRPClusterDistribution)
@@ -210,6 +236,52 @@ func (metric *Metric) OnUDPFlush(flow *flows.UDPFlow) {
 	}
 }
 
+// OnSCTPFlush we first identify the request/response pairs. Based on these,
+// the basic metrics to identify the corresponding cluster can be calculated.
+// Afterwards, all metrics are computed.
+// Session Metrics are called by sessionIdentifier on ForceFlush
+func (metric *Metric) OnSCTPFlush(flow *flows.SCTPFlow) {
+	var protocol = common.GetProtocol(&flow.Flow)
+	reqRes, dropFlow := metric.ReqResIdentifier.OnSCTPFlush(protocol, flow)
+	if dropFlow {
+		return
+	}
+
+	metric.clusterController.CollectAndSetFlowClusterIndex(&flow.Flow, reqRes)
+	metric.clusterController.CollectAndSetRRPClusterIndex(&flow.Flow, reqRes)
+
+	for _, metric := range metric.registeredRRMetrics {
+		metric.OnFlush(protocol, &flow.Flow, reqRes)
+	}
+
+	for _, metric := range metric.registeredFlowMetrics {
+		metric.OnSCTPFlush(flow)
+	}
+}
+
+// OnICMPFlush we first identify the request/response pairs (echo request/reply). Based on these,
+// the basic metrics to identify the corresponding cluster can be calculated.
+// Afterwards, all metrics are computed.
+// Session Metrics are called by sessionIdentifier on ForceFlush
+func (metric *Metric) OnICMPFlush(flow *flows.ICMPFlow) {
+	var protocol = common.GetProtocol(&flow.Flow)
+	reqRes, dropFlow := metric.ReqResIdentifier.OnICMPFlush(protocol, flow)
+	if dropFlow {
+		return
+	}
+
+	metric.clusterController.CollectAndSetRRPClusterIndex(&flow.Flow, reqRes)
+	metric.clusterController.CollectAndSetFlowClusterIndex(&flow.Flow, reqRes)
+
+	for _, metric := range metric.registeredRRMetrics {
+		metric.OnFlush(protocol, &flow.Flow, reqRes)
+	}
+
+	for _, metric := range metric.registeredFlowMetrics {
+		metric.OnICMPFlush(flow)
+	}
+}
+
 // ForceFlush flushes all open sessions, so that session metrics also process the remaining sessions
 func (metric *Metric) ForceFlush() {
 	metric.SessionIdentifier.forceFlush()
@@ -40,6 +40,11 @@ func (mnf *MetricNumFlows) Name() string {
 	return "NumFlows"
 }
 
+// Reset removes all values of the metric
+func (mnf *MetricNumFlows) Reset() {
+	mnf.flows.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mnf *MetricNumFlows) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Number of Flows:")
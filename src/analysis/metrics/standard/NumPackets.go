@@ -26,6 +26,16 @@ func (mnp *MetricNumPackets) OnUDPFlush(flow *flows.UDPFlow) {
 	mnp.numPackets.AddValue(protocol, len(flow.Packets))
 }
 
+func (mnp *MetricNumPackets) OnSCTPFlush(flow *flows.SCTPFlow) {
+	protocol := common.GetProtocol(&(flow.Flow))
+	mnp.numPackets.AddValue(protocol, len(flow.Packets))
+}
+
+func (mnp *MetricNumPackets) OnICMPFlush(flow *flows.ICMPFlow) {
+	protocol := common.GetProtocol(&(flow.Flow))
+	mnp.numPackets.AddValue(protocol, len(flow.Packets))
+}
+
 // Export returns the metric data per Protocol
 func (mnp *MetricNumPackets) Export(protocolKey common.ProtocolKeyType) int {
 	return mnp.numPackets.Export(protocolKey)
@@ -41,6 +51,11 @@ func (mnp *MetricNumPackets) Name() string {
 	return "NumPackets"
 }
 
+// Reset removes all values of the metric
+func (mnp *MetricNumPackets) Reset() {
+	mnp.numPackets.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mnp *MetricNumPackets) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Number of Packets:")
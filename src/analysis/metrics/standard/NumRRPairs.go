@@ -35,6 +35,11 @@ func (mnrrp *MetricNumRRPairs) Name() string {
 	return "NumRRPairs"
 }
 
+// Reset removes all values of the metric
+func (mnrrp *MetricNumRRPairs) Reset() {
+	mnrrp.rrPairs.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mnrrp *MetricNumRRPairs) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Number of RR Pairs:")
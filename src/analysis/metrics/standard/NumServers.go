@@ -45,6 +45,11 @@ func (mns *MetricNumServers) Name() string {
 	return "NumServers"
 }
 
+// Reset removes all values of the metric
+func (mns *MetricNumServers) Reset() {
+	mns.numServers.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mns *MetricNumServers) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Number of Servers:")
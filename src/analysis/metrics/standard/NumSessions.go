@@ -34,6 +34,11 @@ func (mns *MetricNumSessions) Name() string {
 	return "NumSessions"
 }
 
+// Reset removes all values of the metric
+func (mns *MetricNumSessions) Reset() {
+	mns.sessions.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mns *MetricNumSessions) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Number of Sessions:")
@@ -39,6 +39,11 @@ func (mcd *MetricRRPClusterDistribution) Name() string {
 	return "RRPClusterDistribution"
 }
 
+// Reset removes all values of the metric
+func (mcd *MetricRRPClusterDistribution) Reset() {
+	mcd.clusterDistribution.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mcd *MetricRRPClusterDistribution) PrintStatistic(verbose bool) {
 	fmt.Println("Metric RRP Cluster distribution:")
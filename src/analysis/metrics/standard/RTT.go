@@ -0,0 +1,142 @@
+package standard
+
+import (
+	"scalable-flow-analyzer/flows"
+	"scalable-flow-analyzer/metrics/common"
+	"fmt"
+)
+
+// MetricRTT measures the round-trip times of TCP flows at the capture point.
+// For each flow, the end-to-end handshake RTT and the minimal client-side and server-side RTT
+// (of the handshake and all data segment samples) are added.
+// Since the three metrics are calculated together, they are combined in this file.
+type MetricRTT struct {
+	handshake common.IntMetricUnivariate
+	client    common.IntMetricUnivariate
+	server    common.IntMetricUnivariate
+}
+
+func newMetricRTT() *MetricRTT {
+	var metricRTT = MetricRTT{}
+	metricRTT.handshake = common.NewIntMetricUnivariate(1, true)
+	metricRTT.client = common.NewIntMetricUnivariate(1, true)
+	metricRTT.server = common.NewIntMetricUnivariate(1, true)
+	return &metricRTT
+}
+
+type MetricHandshakeRTT struct {
+	metricRTT *MetricRTT
+}
+
+func (mr *MetricRTT) GetHandshake() *MetricHandshakeRTT {
+	return &MetricHandshakeRTT{metricRTT: mr}
+}
+
+// Export returns the metric data per Protocol
+func (mhr *MetricHandshakeRTT) Export(protocolKey common.ProtocolKeyType) *common.ExportUnivariateFormat {
+	return mhr.metricRTT.handshake.Export(protocolKey, DefaultClusterIndex)
+}
+
+// Export the stored protocols
+func (mhr *MetricHandshakeRTT) GetProtocols() []common.Protocol {
+	return mhr.metricRTT.handshake.GetProtocols()
+}
+
+// Name of the Metric
+func (mhr *MetricHandshakeRTT) Name() string {
+	return "HandshakeRTT"
+}
+
+// Reset removes all values of the handshake RTT metric
+func (mhr *MetricHandshakeRTT) Reset() {
+	mhr.metricRTT.handshake.Reset()
+}
+
+type MetricClientRTT struct {
+	metricRTT *MetricRTT
+}
+
+func (mr *MetricRTT) GetClient() *MetricClientRTT {
+	return &MetricClientRTT{metricRTT: mr}
+}
+
+// Export returns the metric data per Protocol
+func (mcr *MetricClientRTT) Export(protocolKey common.ProtocolKeyType) *common.ExportUnivariateFormat {
+	return mcr.metricRTT.client.Export(protocolKey, DefaultClusterIndex)
+}
+
+// Export the stored protocols
+func (mcr *MetricClientRTT) GetProtocols() []common.Protocol {
+	return mcr.metricRTT.client.GetProtocols()
+}
+
+// Name of the Metric
+func (mcr *MetricClientRTT) Name() string {
+	return "ClientRTT"
+}
+
+// Reset removes all values of the client-side RTT metric
+func (mcr *MetricClientRTT) Reset() {
+	mcr.metricRTT.client.Reset()
+}
+
+type MetricServerRTT struct {
+	metricRTT *MetricRTT
+}
+
+func (mr *MetricRTT) GetServer() *MetricServerRTT {
+	return &MetricServerRTT{metricRTT: mr}
+}
+
+// Export returns the metric data per Protocol
+func (msr *MetricServerRTT) Export(protocolKey common.ProtocolKeyType) *common.ExportUnivariateFormat {
+	return msr.metricRTT.server.Export(protocolKey, DefaultClusterIndex)
+}
+
+// Export the stored protocols
+func (msr *MetricServerRTT) GetProtocols() []common.Protocol {
+	return msr.metricRTT.server.GetProtocols()
+}
+
+// Name of the Metric
+func (msr *MetricServerRTT) Name() string {
+	return "ServerRTT"
+}
+
+// Reset removes all values of the server-side RTT metric
+func (msr *MetricServerRTT) Reset() {
+	msr.metricRTT.server.Reset()
+}
+
+func (mr *MetricRTT) OnTCPFlush(flow *flows.TCPFlow) {
+	protocol := common.GetProtocol(&(flow.Flow))
+	rtt := common.GetTCPRTT(flow)
+	if handshake := rtt.Handshake(); handshake >= 0 {
+		mr.handshake.AddValue(protocol, DefaultClusterIndex, int(handshake))
+	}
+	if minClient := rtt.MinClient(); minClient >= 0 {
+		mr.client.AddValue(protocol, DefaultClusterIndex, int(minClient))
+	}
+	if minServer := rtt.MinServer(); minServer >= 0 {
+		mr.server.AddValue(protocol, DefaultClusterIndex, int(minServer))
+	}
+}
+
+// OnUDPFlush is ignored, since the RTT is only measured for TCP
+func (mr *MetricRTT) OnUDPFlush(flow *flows.UDPFlow) {}
+
+// OnSCTPFlush is ignored, since the RTT is only measured for TCP
+func (mr *MetricRTT) OnSCTPFlush(flow *flows.SCTPFlow) {}
+
+// OnICMPFlush is ignored, since the RTT is only measured for TCP
+func (mr *MetricRTT) OnICMPFlush(flow *flows.ICMPFlow) {}
+
+// PrintStatistic prints some statistic to the console
+func (mr *MetricRTT) PrintStatistic(verbose bool) {
+	fmt.Println("Metric Handshake RTT:")
+	fmt.Print(mr.handshake.GetStatistics(verbose))
+	fmt.Println("Metric Client RTT:")
+	fmt.Print(mr.client.GetStatistics(verbose))
+	fmt.Println("Metric Server RTT:")
+	fmt.Print(mr.server.GetStatistics(verbose))
+}
@@ -38,6 +38,11 @@ func (mcd *MetricSessionClusterDistribution) Name() string {
 	return "SessionClusterDistribution"
 }
 
+// Reset removes all values of the metric
+func (mcd *MetricSessionClusterDistribution) Reset() {
+	mcd.clusterDistribution.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mcd *MetricSessionClusterDistribution) PrintStatistic(verbose bool) {
 	fmt.Println("Metric Session Cluster distribution:")
@@ -28,6 +28,7 @@ type session struct {
 type userSessionsStruct struct {
 	sessions         []*session
 	userClusterIndex int
+	clientIPAddress  flows.IPAddress
 	mutex            sync.Mutex
 }
 
@@ -82,7 +83,7 @@ func (si *sessionIdentifier) forceFlush() {
 						sort.Slice(session.flows, func(i, j int) bool {
 							return session.flows[i].start < session.flows[j].start
 						})
-						si.clusterController.CollectAndSetSessionClusterIndex(session, userAddress, &protocol)
+						si.clusterController.CollectAndSetSessionClusterIndex(session, userAddress, userSessions.clientIPAddress, &protocol)
 					}
 
 					si.clusterController.CollectAndSetUserClusterIndex(userSessions, userAddress, &protocol)
@@ -197,7 +198,8 @@ func (si *sessionIdentifier) onFlush(flow *flows.Flow) {
 	} else {
 		// add new session
 		protSessions.usersSessions[userKey] = &userSessionsStruct{
-			sessions: []*session{{start: flowStart, end: flowEnd, flows: []*sessionFlow{newSessionFlow}}},
+			sessions:        []*session{{start: flowStart, end: flowEnd, flows: []*sessionFlow{newSessionFlow}}},
+			clientIPAddress: flow.ClientIPAddress,
 		}
 		protSessions.mutex.Unlock()
 	}
@@ -211,6 +213,14 @@ func (si *sessionIdentifier) OnUDPFlush(flow *flows.UDPFlow) {
 	si.onFlush(&flow.Flow)
 }
 
+func (si *sessionIdentifier) OnSCTPFlush(flow *flows.SCTPFlow) {
+	si.onFlush(&flow.Flow)
+}
+
+func (si *sessionIdentifier) OnICMPFlush(flow *flows.ICMPFlow) {
+	si.onFlush(&flow.Flow)
+}
+
 func (si *sessionIdentifier) PrintStatistic(verbose bool) {
 
 }
@@ -7,6 +7,7 @@ import (
 )
 
 // MetricSize measures the size of the requests and responses.
+// Retransmitted bytes are excluded, if the ReqResIdentifier determined them.
 // Since the metric is calculated in the same way for requests/responses both metrics are combined in this file.
 type MetricSize struct {
 	request  common.IntMetricBivariate
@@ -47,6 +48,11 @@ func (mrs *MetricRequestSize) Name() string {
 	return "RequestSize"
 }
 
+// Reset removes all values of the request size metric
+func (mrs *MetricRequestSize) Reset() {
+	mrs.metricsize.request.Reset()
+}
+
 type MetricResponseSize struct {
 	metricsize *MetricSize
 }
@@ -70,6 +76,11 @@ func (mrs *MetricResponseSize) Name() string {
 	return "ResponseSize"
 }
 
+// Reset removes all values of the response size metric
+func (mrs *MetricResponseSize) Reset() {
+	mrs.metricsize.response.Reset()
+}
+
 func (ms *MetricSize) OnFlush(p common.Protocol, flow *flows.Flow, rrp []*common.RequestResponse) {
 	for i, reqRes := range rrp {
 		var requestSize = 0
@@ -80,6 +91,8 @@ func (ms *MetricSize) OnFlush(p common.Protocol, flow *flows.Flow, rrp []*common
 		for _, response := range reqRes.Responses {
 			responseSize += int(response.LengthPayload)
 		}
+		requestSize -= reqRes.RetransmittedRequestBytes
+		responseSize -= reqRes.RetransmittedResponseBytes
 		ms.request.AddValue(p, reqRes.ClusterIndex, []int{i + 1, requestSize})
 		ms.response.AddValue(p, reqRes.ClusterIndex, []int{i + 1, responseSize})
 	}
@@ -98,6 +111,8 @@ func (ms *MetricSize) calc(rrp []*common.RequestResponse) (reqSizes, resSizes []
 		for _, response := range reqRes.Responses {
 			responseSize += int(response.LengthPayload)
 		}
+		requestSize -= reqRes.RetransmittedRequestBytes
+		responseSize -= reqRes.RetransmittedResponseBytes
 		reqSizes[i] = requestSize
 		resSizes[i] = responseSize
 	}
@@ -34,6 +34,11 @@ func (mcd *MetricUserClusterDistribution) Name() string {
 	return "UserClusterDistribution"
 }
 
+// Reset removes all values of the metric
+func (mcd *MetricUserClusterDistribution) Reset() {
+	mcd.clusterDistribution.Reset()
+}
+
 // PrintStatistic prints some statistic to the console
 func (mcd *MetricUserClusterDistribution) PrintStatistic(verbose bool) {
 	fmt.Println("Metric User Cluster distribution:")
@@ -19,6 +19,7 @@ type MetricProtocolExport interface {
 	Export(common.ProtocolKeyType) int
 	GetProtocols() []common.Protocol
 	Name() string
+	Reset()
 }
 
 // MetricUnivariateExport Interface which must be implemented by the metrics if they shall be included in the exported json file
@@ -26,6 +27,7 @@ type MetricUnivariateExport interface {
 	Export(common.ProtocolKeyType) *common.ExportUnivariateFormat
 	GetProtocols() []common.Protocol
 	Name() string
+	Reset()
 }
 
 // MetricUnivariateExport Interface which must be implemented by the metrics if they shall be included in the exported json file
@@ -33,6 +35,7 @@ type MetricUnivariateClusterExport interface {
 	ExportClusters(common.ProtocolKeyType) *common.ExportUnivariateClusterFormat
 	GetProtocols() []common.Protocol
 	Name() string
+	Reset()
 }
 
 // MetricBivariateExport Interface which must be implemented by the metrics if they shall be included in the exported json file
@@ -40,6 +43,7 @@ type MetricBivariateExport interface {
 	ExportBivariate(common.ProtocolKeyType) *common.ExportBivariateFormat
 	GetProtocols() []common.Protocol
 	Name() string
+	Reset()
 }
 
 // MetricBivariateClusterExport Interface which must be implemented by the metrics if they shall be included in the exported json file
@@ -47,6 +51,7 @@ type MetricBivariateClusterExport interface {
 	ExportBivariateClusters(common.ProtocolKeyType) *common.ExportBivariateClusterFormat
 	GetProtocols() []common.Protocol
 	Name() string
+	Reset()
 }
 
 type exportFormat struct {
@@ -141,3 +146,26 @@ func (metric *Metric) Export(directory string) {
 	}
 	fmt.Println("Export successfull")
 }
+
+// Rotate exports the metrics of a finished rotation interval to the "directory" and resets them afterwards,
+// so that the next interval starts with empty histograms.
+// Open sessions are not flushed, they are included in the interval in which they time out.
+// No flows must be flushed concurrently (see pool.Pools.Pause), otherwise their values are lost by the reset.
+func (metric *Metric) Rotate(directory string) {
+	metric.Export(directory)
+	for _, singleMetric := range metric.allExportedMetrics {
+		singleMetric.Reset()
+	}
+	for _, singleMetric := range metric.allExportedMetricsUnivariate {
+		singleMetric.Reset()
+	}
+	for _, singleMetric := range metric.allExportedMetricsUnivariateCluster {
+		singleMetric.Reset()
+	}
+	for _, singleMetric := range metric.allExportedMetricsBivariate {
+		singleMetric.Reset()
+	}
+	for _, singleMetric := range metric.allExportedMetricsBivariateCluster {
+		singleMetric.Reset()
+	}
+}
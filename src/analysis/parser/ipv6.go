@@ -0,0 +1,139 @@
+package parser
+
+// layers.IPv6 only handles the hop-by-hop header and layers.IPv6ExtensionSkipper only skips a single extension header.
+// This file contains a DecodingLayer, which walks the complete extension header chain and supports jumbograms (RFC 2675).
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Extension headers without constants in gopacket (RFC 7045)
+const ipProtocolMobility layers.IPProtocol = 135
+const ipProtocolHIP layers.IPProtocol = 139
+const ipProtocolShim6 layers.IPProtocol = 140
+
+// ipv6FragmentHeaderLength is the fixed length of the fragment extension header
+const ipv6FragmentHeaderLength = 8
+
+// ipv6 is a gopacket.DecodingLayer for IPv6 including all extension headers.
+// After decoding, Payload starts with the upper layer header (e.g. TCP).
+type ipv6 struct {
+	layers.IPv6
+	// Length of the upper layer (after all extension headers).
+	// For jumbograms the length is taken from the jumbo payload option.
+	PayloadLength uint32
+	// First header which is not an extension header
+	UpperLayerProtocol layers.IPProtocol
+	// Fragment header information. Only valid if Fragmented is true.
+	Fragmented             bool
+	MoreFragments          bool
+	FragmentOffset         uint16 // In 8 byte units
+	FragmentIdentification uint32
+	// Whether encapsulated IP packets are decoded (IP-in-IP)
+	decapsulate bool
+}
+
+// NextLayerType returns the layer type of the upper layer.
+// Like layers.IPv4, LayerTypeFragment is returned for fragments, since the upper layer is only complete after reassembly.
+func (i *ipv6) NextLayerType() gopacket.LayerType {
+	if i.Fragmented {
+		return gopacket.LayerTypeFragment
+	}
+	if !i.decapsulate && (i.UpperLayerProtocol == layers.IPProtocolIPv4 || i.UpperLayerProtocol == layers.IPProtocolIPv6) {
+		return gopacket.LayerTypeZero
+	}
+	return i.UpperLayerProtocol.LayerType()
+}
+
+// DecodeFromBytes decodes the IPv6 header and walks the extension header chain.
+func (i *ipv6) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	err := i.IPv6.DecodeFromBytes(data, df)
+	if err != nil {
+		return err
+	}
+	i.Fragmented = false
+	i.MoreFragments = false
+	i.FragmentOffset = 0
+	i.FragmentIdentification = 0
+	i.UpperLayerProtocol = i.NextHeader
+
+	payload := i.IPv6.Payload
+	var length = int(i.Length)
+	if i.HopByHop != nil {
+		i.UpperLayerProtocol = i.HopByHop.NextHeader
+		hopByHopLength := i.HopByHop.ActualLength
+		if i.Length == 0 {
+			// Jumbogram: layers.IPv6 did not remove the hop-by-hop header from the payload
+			length = int(getJumboPayloadLength(i.HopByHop))
+			if hopByHopLength > len(payload) {
+				hopByHopLength = len(payload)
+			}
+			payload = payload[hopByHopLength:]
+		}
+		length -= i.HopByHop.ActualLength
+	}
+
+	// Walk the remaining extension headers
+	for walk := true; walk; {
+		var headerLength int
+		switch i.UpperLayerProtocol {
+		case layers.IPProtocolIPv6HopByHop, layers.IPProtocolIPv6Routing, layers.IPProtocolIPv6Destination,
+			ipProtocolMobility, ipProtocolHIP, ipProtocolShim6:
+			if len(payload) < 2 {
+				walk = false
+				continue
+			}
+			headerLength = (int(payload[1]) + 1) * 8
+		case layers.IPProtocolAH:
+			if len(payload) < 2 {
+				walk = false
+				continue
+			}
+			headerLength = (int(payload[1]) + 2) * 4
+		case layers.IPProtocolIPv6Fragment:
+			if len(payload) < ipv6FragmentHeaderLength {
+				walk = false
+				continue
+			}
+			headerLength = ipv6FragmentHeaderLength
+			i.Fragmented = true
+			i.FragmentOffset = binary.BigEndian.Uint16(payload[2:4]) >> 3
+			i.MoreFragments = payload[3]&0x1 != 0
+			i.FragmentIdentification = binary.BigEndian.Uint32(payload[4:8])
+		default:
+			walk = false
+			continue
+		}
+		i.UpperLayerProtocol = layers.IPProtocol(payload[0])
+		length -= headerLength
+		if headerLength > len(payload) {
+			headerLength = len(payload)
+		}
+		payload = payload[headerLength:]
+	}
+
+	if length < 0 {
+		return errors.New("IPv6 extension headers exceed payload length")
+	}
+	i.PayloadLength = uint32(length)
+	if length < len(payload) {
+		payload = payload[:length]
+	}
+	i.IPv6.Payload = payload
+	return nil
+}
+
+// getJumboPayloadLength returns the length of the jumbo payload option.
+// The option was already validated by layers.IPv6.
+func getJumboPayloadLength(hopByHop *layers.IPv6HopByHop) uint32 {
+	for _, option := range hopByHop.Options {
+		if option.OptionType == layers.IPv6HopByHopOptionJumbogram && len(option.OptionData) == 4 {
+			return binary.BigEndian.Uint32(option.OptionData)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// tcpPayloadLength is the length of the upper layer of the crafted packets
+const tcpPayloadLength = 20
+
+// craftIPv6Packet returns an IPv6 packet with the extension headers followed by an upper layer of upperLength bytes.
+// If payloadLength is negative, the length is computed from the extension headers and the upper layer.
+func craftIPv6Packet(nextHeader layers.IPProtocol, payloadLength int, extensionHeaders []byte, upperLength int) []byte {
+	if payloadLength < 0 {
+		payloadLength = len(extensionHeaders) + upperLength
+	}
+	data := make([]byte, 40, 40+len(extensionHeaders)+upperLength)
+	data[0] = 0x60
+	binary.BigEndian.PutUint16(data[4:6], uint16(payloadLength))
+	data[6] = byte(nextHeader)
+	data[7] = 64
+	data[23] = 1 // Source ::1
+	data[39] = 2 // Destination ::2
+	data = append(data, extensionHeaders...)
+	return append(data, make([]byte, upperLength)...)
+}
+
+// jumboHopByHop is a hop-by-hop header containing the jumbo payload option with the length
+func jumboHopByHop(nextHeader layers.IPProtocol, length uint32) []byte {
+	header := []byte{byte(nextHeader), 0, byte(layers.IPv6HopByHopOptionJumbogram), 4, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], length)
+	return header
+}
+
+func TestIPv6ExtensionHeaders(t *testing.T) {
+	tcp := byte(layers.IPProtocolTCP)
+	// Pad6 (PadN with 4 bytes of data), so the options fill the 8 byte header
+	padN := []byte{1, 4, 0, 0, 0, 0}
+	jumboLength := uint32(70000)
+
+	tests := []struct {
+		name               string
+		packet             []byte
+		upperLayerProtocol layers.IPProtocol
+		nextLayerType      gopacket.LayerType
+		payloadOffset      int
+		payloadLength      uint32
+		fragmented         bool
+	}{
+		{
+			name:               "no extension header",
+			packet:             craftIPv6Packet(layers.IPProtocolTCP, -1, nil, tcpPayloadLength),
+			upperLayerProtocol: layers.IPProtocolTCP,
+			nextLayerType:      layers.LayerTypeTCP,
+			payloadOffset:      40,
+			payloadLength:      tcpPayloadLength,
+		},
+		{
+			name:               "hop-by-hop",
+			packet:             craftIPv6Packet(layers.IPProtocolIPv6HopByHop, -1, append([]byte{tcp, 0}, padN...), tcpPayloadLength),
+			upperLayerProtocol: layers.IPProtocolTCP,
+			nextLayerType:      layers.LayerTypeTCP,
+			payloadOffset:      48,
+			payloadLength:      tcpPayloadLength,
+		},
+		{
+			name:               "routing",
+			packet:             craftIPv6Packet(layers.IPProtocolIPv6Routing, -1, []byte{tcp, 0, 0, 0, 0, 0, 0, 0}, tcpPayloadLength),
+			upperLayerProtocol: layers.IPProtocolTCP,
+			nextLayerType:      layers.LayerTypeTCP,
+			payloadOffset:      48,
+			payloadLength:      tcpPayloadLength,
+		},
+		{
+			name: "fragment",
+			// Offset 0, more fragments, identification 0x12345678
+			packet:             craftIPv6Packet(layers.IPProtocolIPv6Fragment, -1, []byte{tcp, 0, 0, 1, 0x12, 0x34, 0x56, 0x78}, tcpPayloadLength),
+			upperLayerProtocol: layers.IPProtocolTCP,
+			nextLayerType:      gopacket.LayerTypeFragment,
+			payloadOffset:      48,
+			payloadLength:      tcpPayloadLength,
+			fragmented:         true,
+		},
+		{
+			name:               "destination options",
+			packet:             craftIPv6Packet(layers.IPProtocolIPv6Destination, -1, append([]byte{tcp, 0}, padN...), tcpPayloadLength),
+			upperLayerProtocol: layers.IPProtocolTCP,
+			nextLayerType:      layers.LayerTypeTCP,
+			payloadOffset:      48,
+			payloadLength:      tcpPayloadLength,
+		},
+		{
+			name: "chain of hop-by-hop, routing and destination options",
+			packet: craftIPv6Packet(layers.IPProtocolIPv6HopByHop, -1, append(append(
+				append([]byte{byte(layers.IPProtocolIPv6Routing), 0}, padN...),
+				byte(layers.IPProtocolIPv6Destination), 0, 0, 0, 0, 0, 0, 0),
+				append([]byte{tcp, 0}, padN...)...), tcpPayloadLength),
+			upperLayerProtocol: layers.IPProtocolTCP,
+			nextLayerType:      layers.LayerTypeTCP,
+			payloadOffset:      64,
+			payloadLength:      tcpPayloadLength,
+		},
+		{
+			name:               "jumbogram",
+			packet:             craftIPv6Packet(layers.IPProtocolIPv6HopByHop, 0, jumboHopByHop(layers.IPProtocolTCP, jumboLength), int(jumboLength)-8),
+			upperLayerProtocol: layers.IPProtocolTCP,
+			nextLayerType:      layers.LayerTypeTCP,
+			payloadOffset:      48,
+			payloadLength:      jumboLength - 8,
+		},
+		{
+			name: "jumbogram truncated by the snap length",
+			packet: craftIPv6Packet(layers.IPProtocolIPv6HopByHop, 0,
+				jumboHopByHop(layers.IPProtocolTCP, jumboLength), int(jumboLength)-8)[:1500],
+			upperLayerProtocol: layers.IPProtocolTCP,
+			nextLayerType:      layers.LayerTypeTCP,
+			payloadOffset:      48,
+			payloadLength:      jumboLength - 8,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var decoder ipv6
+			err := decoder.DecodeFromBytes(test.packet, gopacket.NilDecodeFeedback)
+			if err != nil {
+				t.Fatalf("DecodeFromBytes failed: %v", err)
+			}
+			if decoder.UpperLayerProtocol != test.upperLayerProtocol {
+				t.Errorf("UpperLayerProtocol = %v, want %v", decoder.UpperLayerProtocol, test.upperLayerProtocol)
+			}
+			if decoder.NextLayerType() != test.nextLayerType {
+				t.Errorf("NextLayerType = %v, want %v", decoder.NextLayerType(), test.nextLayerType)
+			}
+			// The payload is a sub slice of the packet, so the offset follows from the capacities
+			if offset := cap(test.packet) - cap(decoder.Payload); offset != test.payloadOffset {
+				t.Errorf("payload offset = %d, want %d", offset, test.payloadOffset)
+			}
+			if decoder.PayloadLength != test.payloadLength {
+				t.Errorf("PayloadLength = %d, want %d", decoder.PayloadLength, test.payloadLength)
+			}
+			if decoder.Fragmented != test.fragmented {
+				t.Errorf("Fragmented = %v, want %v", decoder.Fragmented, test.fragmented)
+			}
+		})
+	}
+}
+
+func TestIPv6Fragment(t *testing.T) {
+	// Offset 185 (1480 bytes), no more fragments, identification 0x12345678
+	fragmentHeader := []byte{byte(layers.IPProtocolUDP), 0, 0x05, 0xc8, 0x12, 0x34, 0x56, 0x78}
+	packet := craftIPv6Packet(layers.IPProtocolIPv6Fragment, -1, fragmentHeader, tcpPayloadLength)
+
+	var decoder ipv6
+	if err := decoder.DecodeFromBytes(packet, gopacket.NilDecodeFeedback); err != nil {
+		t.Fatalf("DecodeFromBytes failed: %v", err)
+	}
+	if decoder.FragmentOffset != 185 || decoder.MoreFragments || decoder.FragmentIdentification != 0x12345678 {
+		t.Errorf("fragment = (offset %d, more %v, id %#x), want (185, false, 0x12345678)",
+			decoder.FragmentOffset, decoder.MoreFragments, decoder.FragmentIdentification)
+	}
+}
+
+func TestIPv6ExtensionHeadersExceedLength(t *testing.T) {
+	// The payload length only covers half of the routing header
+	packet := craftIPv6Packet(layers.IPProtocolIPv6Routing, 4, []byte{byte(layers.IPProtocolTCP), 0, 0, 0, 0, 0, 0, 0}, 0)
+
+	var decoder ipv6
+	if err := decoder.DecodeFromBytes(packet, gopacket.NilDecodeFeedback); err == nil {
+		t.Error("DecodeFromBytes succeeded, want error")
+	}
+}
@@ -0,0 +1,198 @@
+package parser
+
+// This file contains the selection of the first decoder based on the link type of the capture
+// and the decoding layers for link types, which are not (or not efficiently) supported by gopacket.
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// LinkTypeLinuxSLL2 is used for Linux cooked captures v2 (tcpdump -i any, DLT 276).
+// gopacket stores link types as uint8, therefore the unassigned value 20 is used instead (see LinkTypeFromDLT).
+const LinkTypeLinuxSLL2 layers.LinkType = 20
+
+// dltLinuxSLL2 is the DLT of Linux cooked captures v2 in pcap and pcapng files
+const dltLinuxSLL2 = 276
+
+// linkTypeUnknown is used for DLTs, which cannot be stored in a layers.LinkType. It is not supported.
+const linkTypeUnknown layers.LinkType = 0xff
+
+// LinkTypeFromDLT returns the link type of a DLT read from a pcap or pcapng file.
+// Unlike gopacket, DLTs above 255 are not truncated, e.g. DLT 257 must not be decoded as Ethernet.
+func LinkTypeFromDLT(dlt uint32) layers.LinkType {
+	switch {
+	case dlt == dltLinuxSLL2:
+		return LinkTypeLinuxSLL2
+	case dlt > 0xff || dlt == uint32(LinkTypeLinuxSLL2):
+		return linkTypeUnknown
+	default:
+		return layers.LinkType(dlt)
+	}
+}
+
+// layerTypeLinuxSLL2 is the layer type of Linux cooked capture v2 headers
+var layerTypeLinuxSLL2 = gopacket.RegisterLayerType(1000, gopacket.LayerTypeMetadata{Name: "LinuxSLL2"})
+
+// sll2HeaderLength is the length of the Linux cooked capture v2 header
+const sll2HeaderLength = 20
+
+// IsLinkTypeSupported returns whether packets of the link type can be decoded
+func IsLinkTypeSupported(linkType layers.LinkType) bool {
+	switch linkType {
+	case layers.LinkTypeEthernet, layers.LinkTypeRaw, layers.LinkTypeIPv4, layers.LinkTypeIPv6,
+		layers.LinkTypeNull, layers.LinkTypeLoop, layers.LinkTypeLinuxSLL, LinkTypeLinuxSLL2,
+		layers.LinkTypeIEEE802_11, layers.LinkTypeIEEE80211Radio:
+		return true
+	default:
+		return false
+	}
+}
+
+// getFirstLayerType returns the layer type the packet starts with.
+// Returns gopacket.LayerTypeZero for unsupported link types.
+func getFirstLayerType(linkType layers.LinkType, data []byte) gopacket.LayerType {
+	switch linkType {
+	case layers.LinkTypeEthernet:
+		return layers.LayerTypeEthernet
+	case layers.LinkTypeRaw:
+		// Raw IP can contain IPv4 and IPv6 packets
+		if len(data) > 0 && data[0]>>4 == 6 {
+			return layers.LayerTypeIPv6
+		}
+		return layers.LayerTypeIPv4
+	case layers.LinkTypeIPv4:
+		return layers.LayerTypeIPv4
+	case layers.LinkTypeIPv6:
+		return layers.LayerTypeIPv6
+	case layers.LinkTypeNull, layers.LinkTypeLoop:
+		return layers.LayerTypeLoopback
+	case layers.LinkTypeLinuxSLL:
+		return layers.LayerTypeLinuxSLL
+	case LinkTypeLinuxSLL2:
+		return layerTypeLinuxSLL2
+	case layers.LinkTypeIEEE802_11:
+		return layers.LayerTypeDot11
+	case layers.LinkTypeIEEE80211Radio:
+		return layers.LayerTypeRadioTap
+	default:
+		return gopacket.LayerTypeZero
+	}
+}
+
+// firstLayerTypes contains all layer types returned by getFirstLayerType
+var firstLayerTypes = []gopacket.LayerType{
+	layers.LayerTypeEthernet, layers.LayerTypeIPv4, layers.LayerTypeIPv6, layers.LayerTypeLoopback,
+	layers.LayerTypeLinuxSLL, layerTypeLinuxSLL2, layers.LayerTypeDot11, layers.LayerTypeRadioTap,
+}
+
+// linkLayers contains the decoding layers of all supported link types (except Ethernet)
+type linkLayers struct {
+	loopback layers.Loopback
+	sll      layers.LinuxSLL
+	sll2     sll2
+	radioTap layers.RadioTap
+	dot11    dot11
+	llc      layers.LLC
+	snap     layers.SNAP
+}
+
+// getDecodingLayers returns the decoding layers of all supported link types
+func (l *linkLayers) getDecodingLayers() []gopacket.DecodingLayer {
+	return []gopacket.DecodingLayer{&l.loopback, &l.sll, &l.sll2, &l.radioTap, &l.dot11, &l.llc, &l.snap}
+}
+
+// sll2 is a gopacket.DecodingLayer for Linux cooked capture v2 headers, which are unknown to gopacket.
+type sll2 struct {
+	layers.BaseLayer
+	EthernetType layers.EthernetType
+}
+
+// CanDecode returns the layer type this DecodingLayer can decode
+func (s *sll2) CanDecode() gopacket.LayerClass {
+	return layerTypeLinuxSLL2
+}
+
+// NextLayerType returns the layer type of the protocol type field
+func (s *sll2) NextLayerType() gopacket.LayerType {
+	return s.EthernetType.LayerType()
+}
+
+// DecodeFromBytes decodes the header: protocol type (2), reserved (2), interface index (4),
+// ARPHRD type (2), packet type (1), address length (1) and address (8)
+func (s *sll2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < sll2HeaderLength {
+		df.SetTruncated()
+		return errors.New("Linux SLL2 packet too short")
+	}
+	s.EthernetType = layers.EthernetType(binary.BigEndian.Uint16(data[0:2]))
+	s.BaseLayer = layers.BaseLayer{Contents: data[:sll2HeaderLength], Payload: data[sll2HeaderLength:]}
+	return nil
+}
+
+// IEEE 802.11 frame control fields
+const dot11TypeData = 2
+const dot11SubtypeQoS = 0x8
+const dot11FlagToDS = 0x01
+const dot11FlagFromDS = 0x02
+const dot11FlagProtected = 0x40
+const dot11FlagOrder = 0x80
+
+// dot11HeaderLength is the length of the 802.11 data frame header with three addresses
+const dot11HeaderLength = 24
+
+// dot11 is a gopacket.DecodingLayer for IEEE 802.11 data frames.
+// layers.Dot11 allocates a layer for every data frame and always removes a frame check sequence.
+// Management, control and protected frames are not decoded any further.
+type dot11 struct {
+	layers.BaseLayer
+	isData bool
+}
+
+// CanDecode returns the layer type this DecodingLayer can decode
+func (d *dot11) CanDecode() gopacket.LayerClass {
+	return layers.LayerTypeDot11
+}
+
+// NextLayerType returns LayerTypeLLC for unprotected data frames
+func (d *dot11) NextLayerType() gopacket.LayerType {
+	if !d.isData || len(d.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	return layers.LayerTypeLLC
+}
+
+// DecodeFromBytes decodes the length of the header
+func (d *dot11) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 2 {
+		df.SetTruncated()
+		return errors.New("802.11 frame too short")
+	}
+	frameType := (data[0] >> 2) & 0x3
+	subtype := data[0] >> 4
+	flags := data[1]
+	d.isData = frameType == dot11TypeData && flags&dot11FlagProtected == 0
+
+	headerLength := dot11HeaderLength
+	if flags&dot11FlagToDS != 0 && flags&dot11FlagFromDS != 0 {
+		// Fourth address
+		headerLength += 6
+	}
+	if frameType == dot11TypeData && subtype&dot11SubtypeQoS != 0 {
+		// QoS control
+		headerLength += 2
+		if flags&dot11FlagOrder != 0 {
+			// HT control
+			headerLength += 4
+		}
+	}
+	if len(data) < headerLength {
+		d.isData = false
+		headerLength = len(data)
+	}
+	d.BaseLayer = layers.BaseLayer{Contents: data[:headerLength], Payload: data[headerLength:]}
+	return nil
+}
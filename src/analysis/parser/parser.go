@@ -8,6 +8,7 @@ import (
 	"math"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cespare/xxhash"
@@ -20,7 +21,8 @@ import (
 const parserChannelSize = 40000
 
 // packetDataCacheSize is the batching size of the packets sent to the Parsers
-const packetDataCacheSize = 1600
+// Note: Channel elements are limited to 64kB, so the batch (packetDataCacheSize * size of PacketData) must not exceed it
+const packetDataCacheSize = 1300
 
 const ringBufferFlushChannelSize = 200
 
@@ -28,14 +30,20 @@ const ringBufferFlushChannelSize = 200
 type Parser struct {
 	numFlowThreads       uint64
 	parsePacketDataCache packetDataCache
+	lastPacketIdx        int64 // Index of the last packet passed to ParsePacket
 	pool                 *pool.Pools
 	samplingrate         float64
+	samplingModulo       uint64
 	numParserChannel     int
 	parserChannel        []chan [packetDataCacheSize]PacketData
+	quicPorts            [65536]bool // UDP ports on which QUIC headers are parsed
+	quicTracker          *quicTracker
+	reassembler          *reassembler // nil if fragments are not reassembled
+	decapsulation        tunnelDecapsulation
 
 	ringbufferUsedlist     []bool // Same size as ringbuffer. Indicates whether a ringbuffer entry is used or not
 	ringbuffer             []flows.PacketInformation
-	ringbufferStart        int64
+	ringbufferStart        int64 // Index of the next packet to flush, all packets before have been added to the pools
 	ringbufferSize         int64
 	ringbufferFlushChannel chan bool
 
@@ -48,6 +56,8 @@ type PacketData struct {
 	Data      []byte
 	Timestamp int64
 	PacketIdx int64
+	LinkType  layers.LinkType
+	Interface uint32 // Capture interface (pcapng interface ID)
 }
 
 type packetDataCache struct {
@@ -56,7 +66,9 @@ type packetDataCache struct {
 }
 
 // NewParser returns a new parser
-func NewParser(p *pool.Pools, sortingRingBufferSize int64, numParserThreads int, samplingrate float64, numParserChannel int) *Parser {
+// UDP packets from or to one of the quicPorts are parsed as QUIC and tracked by their connection IDs.
+// Packets of the tunnel protocols in decapsulate (gre, vxlan, gtpu, mpls, ipip) are decapsulated.
+func NewParser(p *pool.Pools, sortingRingBufferSize int64, numParserThreads int, samplingrate float64, numParserChannel int, quicPorts []uint16, decapsulate []string) *Parser {
 	var parser = &Parser{
 		pool:                   p,
 		samplingrate:           samplingrate,
@@ -68,6 +80,16 @@ func NewParser(p *pool.Pools, sortingRingBufferSize int64, numParserThreads int,
 		ringbufferSize:         sortingRingBufferSize,
 		ringbufferFlushChannel: make(chan bool, ringBufferFlushChannelSize),
 		numFlowThreads:         uint64(p.GetNumFlowThreads()),
+		quicTracker:            newQUICTracker(),
+		decapsulation:          newTunnelDecapsulation(decapsulate),
+	}
+	for _, port := range quicPorts {
+		parser.quicPorts[port] = true
+	}
+	parser.samplingModulo = 1
+	// ensure that modulo is really 1, when 100 percent sampling rate (due to float conversion)
+	if samplingrate != 100 {
+		parser.samplingModulo = uint64(float64(parser.numFlowThreads) * (100 / samplingrate))
 	}
 	parser.wgParserThreads.Add(numParserThreads)
 	parser.parserChannel = make([]chan [packetDataCacheSize]PacketData, parser.numParserChannel)
@@ -98,40 +120,76 @@ func (p *Parser) Close() {
 	p.ringbufferFlushChannel <- true
 	close(p.ringbufferFlushChannel)
 	p.wgRingbufferFlush.Wait()
+
+	if p.reassembler != nil {
+		p.reassembler.close()
+	}
+}
+
+// EnableReassembly enables the reassembly of IP fragments. Must be called before the first packet is parsed.
+// Fragments of incomplete datagrams are dropped after timeout (nanoseconds, relative to packet timestamps).
+// maxMemory limits the buffered fragment data in bytes, the oldest datagrams are dropped if it is exceeded.
+func (p *Parser) EnableReassembly(timeout int64, maxMemory int) {
+	p.reassembler = newReassembler(p, timeout, maxMemory)
+}
+
+// PrintStatistics prints some statistics about the parser
+func (p *Parser) PrintStatistics() {
+	if p.reassembler != nil {
+		p.reassembler.printStatistics()
+	}
 }
 
 // ParsePacket adds a packet to the parser (buffered)
-func (p *Parser) ParsePacket(data []byte, packetIdx, packetTimestamp int64) {
-	p.parsePacketDataCache.buf[p.parsePacketDataCache.pos] = PacketData{Data: data, PacketIdx: packetIdx, Timestamp: packetTimestamp}
+// The link type of the capture defines how the packet is decoded, see IsLinkTypeSupported.
+func (p *Parser) ParsePacket(data []byte, packetIdx, packetTimestamp int64, linkType layers.LinkType, captureInterface uint32) {
+	p.parsePacketDataCache.buf[p.parsePacketDataCache.pos] = PacketData{Data: data, PacketIdx: packetIdx, Timestamp: packetTimestamp, LinkType: linkType, Interface: captureInterface}
 	p.parsePacketDataCache.pos++
+	p.lastPacketIdx = packetIdx
 	if p.parsePacketDataCache.pos == packetDataCacheSize {
 		p.parserChannel[rand.Intn(p.numParserChannel)] <- p.parsePacketDataCache.buf
 		p.parsePacketDataCache.pos = 0
 	}
 }
 
+// Drain blocks until all packets passed to ParsePacket have been added to the flows of the pools,
+// e.g. so that the metrics of a rotation interval are complete. Must be called from the goroutine calling ParsePacket.
+func (p *Parser) Drain() {
+	if p.parsePacketDataCache.pos > 0 {
+		// Only the packets of the cache, the remaining entries are left over from the previous batch
+		tmpPacketsCache := packetDataCache{}
+		copy(tmpPacketsCache.buf[:p.parsePacketDataCache.pos], p.parsePacketDataCache.buf[:p.parsePacketDataCache.pos])
+		p.parserChannel[rand.Intn(p.numParserChannel)] <- tmpPacketsCache.buf
+		p.parsePacketDataCache.pos = 0
+	}
+	for atomic.LoadInt64(&p.ringbufferStart) <= p.lastPacketIdx {
+		p.ringbufferFlushChannel <- true
+		time.Sleep(1 * time.Millisecond)
+	}
+	// All packets have been passed to the pools, so no packet is added concurrently
+	p.pool.Drain()
+}
+
 // parsePacket is the internal method, called when the internal cache/buffer is full
 func (p *Parser) parsePacket(channel chan [packetDataCacheSize]PacketData, parserIndex int) {
 	var dot1q layers.Dot1Q
-	var gre layers.GRE
 	var eth layers.Ethernet
 
-	var ipv4 layers.IPv4
-	var ipv6 layers.IPv6
-	var ipv6e layers.IPv6ExtensionSkipper
-	var tcp layers.TCP
-	var udp layers.UDP
-	var samplingModulo uint64 = 1
-	// ensure that modulo is really 1, when 100 percent sampling rate (due to float conversion)
-	if p.samplingrate != 100 {
-		samplingModulo = uint64(float64(p.numFlowThreads) * (100 / p.samplingrate))
-	}
+	ipv4 := ipv4{decapsulate: p.decapsulation.ipip}
+	ipv6 := ipv6{decapsulate: p.decapsulation.ipip}
+	var link linkLayers
+	var tunnel tunnelLayers
+	var transport transportLayers
 
-	parser := gopacket.NewDecodingLayerParser(
-		layers.LayerTypeEthernet,
-		&dot1q, &eth, &gre, &ipv4, &ipv6, &ipv6e, &tcp, &udp)
-	parserIPv4 := gopacket.NewDecodingLayerParser(layers.LayerTypeIPv4, &ipv4, &tcp, &udp)
-	parserIPv6 := gopacket.NewDecodingLayerParser(layers.LayerTypeIPv6, &ipv6, &ipv6e, &tcp, &udp)
+	decodingLayers := []gopacket.DecodingLayer{&dot1q, &eth, &ipv4, &ipv6,
+		&transport.tcp, &transport.udp, &transport.sctp, &transport.icmp4, &transport.icmp6, &transport.icmp6echo}
+	decodingLayers = append(decodingLayers, link.getDecodingLayers()...)
+	decodingLayers = append(decodingLayers, tunnel.getDecodingLayers(p.decapsulation)...)
+	// One parser for each first layer (depends on the link type)
+	parsers := make(map[gopacket.LayerType]*gopacket.DecodingLayerParser)
+	for _, layerType := range firstLayerTypes {
+		parsers[layerType] = gopacket.NewDecodingLayerParser(layerType, decodingLayers...)
+	}
 	var decoded []gopacket.LayerType
 	for packets := range channel {
 		for _, packet := range &packets {
@@ -139,67 +197,53 @@ func (p *Parser) parsePacket(channel chan [packetDataCacheSize]PacketData, parse
 			if packet.PacketIdx == 0 {
 				continue
 			}
-			_ = parserIPv4.DecodeLayers(packet.Data, &decoded)
-			if len(decoded) < 2 {
+			decoded = decoded[:0]
+			if parser, exists := parsers[getFirstLayerType(packet.LinkType, packet.Data)]; exists {
 				_ = parser.DecodeLayers(packet.Data, &decoded)
-				if len(decoded) < 2 {
-					_ = parserIPv6.DecodeLayers(packet.Data, &decoded)
+			}
+			packetInfo := flows.PacketInformation{Timestamp: packet.Timestamp, PacketIdx: packet.PacketIdx, Interface: packet.Interface}
+			// Layers in front of the innermost IP header belong to tunnels
+			innermostIP := 0
+			for i, layerType := range decoded {
+				if layerType == layers.LayerTypeIPv4 || layerType == layers.LayerTypeIPv6 {
+					innermostIP = i
 				}
 			}
-			packetInfo := flows.PacketInformation{Timestamp: packet.Timestamp, PacketIdx: packet.PacketIdx}
-			var ipLength uint16
-			for _, layerType := range decoded {
+			tunnel.setTunnelInformation(&packetInfo, decoded[:innermostIP])
+			var ipLength uint32
+			for _, layerType := range decoded[innermostIP:] {
 				switch layerType {
 				case layers.LayerTypeIPv4:
-					ipLength = ipv4.Length - (uint16(ipv4.IHL) * 4)
+					ipLength = uint32(ipv4.Length) - (uint32(ipv4.IHL) * 4)
 					packetInfo.SrcIP = xxhash.Sum64(ipv4.SrcIP)
 					packetInfo.DstIP = xxhash.Sum64(ipv4.DstIP)
-				case layers.LayerTypeIPv6:
-					ipLength = ipv6.Length
-					// if zero
-					if ipLength == 0 {
-						fmt.Println("Jumbogram detected. Currently unsupported.")
-					}
-					// Subtract possible extension header length
-					if len(ipv6e.Contents) != 0 {
-						// since ipv6 can contain more than one extension header: search for last extens
-						// TODO: search for last extension and remove them from iplength
-						ipLength -= uint16(len(ipv6e.Contents))
-						ipv6e.Contents = make([]byte, 0)
+					packetInfo.SrcIPAddress = flows.NewIPAddress(ipv4.SrcIP)
+					packetInfo.DstIPAddress = flows.NewIPAddress(ipv4.DstIP)
+					if p.reassembler != nil && (ipv4.Flags&layers.IPv4MoreFragments != 0 || ipv4.FragOffset != 0) {
+						packetInfo.Fragment = newIPFragment(ipv4.Payload, uint32(ipv4.FragOffset)*8, ipLength,
+							uint32(ipv4.Id), uint8(ipv4.Protocol), ipv4.Flags&layers.IPv4MoreFragments != 0, false)
 					}
+				case layers.LayerTypeIPv6:
+					// Without extension headers, read from the jumbo payload option for jumbograms
+					ipLength = ipv6.PayloadLength
 					packetInfo.SrcIP = xxhash.Sum64(ipv6.SrcIP)
 					packetInfo.DstIP = xxhash.Sum64(ipv6.DstIP)
-				case layers.LayerTypeTCP:
-					packetInfo.HasTCP = true
-					packetInfo.TCPSYN = tcp.SYN
-					packetInfo.TCPACK = tcp.ACK
-					packetInfo.TCPRST = tcp.RST
-					packetInfo.TCPFIN = tcp.FIN
-					packetInfo.SrcPort = uint16(tcp.SrcPort)
-					packetInfo.DstPort = uint16(tcp.DstPort)
-					packetInfo.TCPSeqNr = tcp.Seq
-					packetInfo.TCPAckNr = tcp.Ack
-					packetInfo.PayloadLength = ipLength - (uint16(tcp.DataOffset) * 4) // Data offset in 32 bits words
-					packetInfo.FlowKey = GetFlowKey(packetInfo.SrcIP, packetInfo.DstIP, flows.TCP, packetInfo.SrcPort, packetInfo.DstPort)
-				case layers.LayerTypeUDP:
-					packetInfo.HasUDP = true
-					packetInfo.SrcPort = uint16(udp.SrcPort)
-					packetInfo.DstPort = uint16(udp.DstPort)
-					packetInfo.PayloadLength = udp.Length
-					packetInfo.FlowKey = GetFlowKey(packetInfo.SrcIP, packetInfo.DstIP, flows.UDP, packetInfo.SrcPort, packetInfo.DstPort)
+					packetInfo.SrcIPAddress = flows.NewIPAddress(ipv6.SrcIP)
+					packetInfo.DstIPAddress = flows.NewIPAddress(ipv6.DstIP)
+					if p.reassembler != nil && ipv6.Fragmented {
+						packetInfo.Fragment = newIPFragment(ipv6.Payload, uint32(ipv6.FragmentOffset)*8, ipLength,
+							ipv6.FragmentIdentification, uint8(ipv6.UpperLayerProtocol), ipv6.MoreFragments, true)
+					}
+				default:
+					p.setTransportInformation(&packetInfo, layerType, ipLength, &transport)
 				}
 			}
 
-			for packetInfo.PacketIdx-p.ringbufferStart > p.ringbufferSize {
+			for packetInfo.PacketIdx-atomic.LoadInt64(&p.ringbufferStart) > p.ringbufferSize {
 				time.Sleep(1 * time.Second)
 				fmt.Println("Parser", parserIndex, ": Sleep for 1s due to missing space in ringbuffer.")
 				fmt.Println("Parser", parserIndex, ": Please increase sortingRingBufferSize variable or increase number of pool to speed up flushing if this happens more often.")
 			}
-			// Sampling
-			if uint64(packetInfo.FlowKey)%samplingModulo > p.numFlowThreads {
-				packetInfo.HasTCP = false
-				packetInfo.HasUDP = false
-			}
 			ringBufferIndex := packetInfo.PacketIdx % p.ringbufferSize
 			p.ringbuffer[ringBufferIndex] = packetInfo
 			p.ringbufferUsedlist[ringBufferIndex] = true
@@ -211,6 +255,108 @@ func (p *Parser) parsePacket(channel chan [packetDataCacheSize]PacketData, parse
 	p.wgParserThreads.Done()
 }
 
+// transportLayers contains the decoding layers of all supported transport protocols
+type transportLayers struct {
+	tcp       layers.TCP
+	udp       layers.UDP
+	sctp      sctp
+	icmp4     layers.ICMPv4
+	icmp6     layers.ICMPv6
+	icmp6echo layers.ICMPv6Echo
+}
+
+// setTransportInformation sets the transport specific fields of the packet information for a decoded layer.
+// ipLength is the length of the IP payload (after all extension headers).
+func (p *Parser) setTransportInformation(packetInfo *flows.PacketInformation, layerType gopacket.LayerType, ipLength uint32, t *transportLayers) {
+	switch layerType {
+	case layers.LayerTypeTCP:
+		packetInfo.HasTCP = true
+		packetInfo.TCPSYN = t.tcp.SYN
+		packetInfo.TCPACK = t.tcp.ACK
+		packetInfo.TCPRST = t.tcp.RST
+		packetInfo.TCPFIN = t.tcp.FIN
+		packetInfo.SrcPort = uint16(t.tcp.SrcPort)
+		packetInfo.DstPort = uint16(t.tcp.DstPort)
+		packetInfo.TCPSeqNr = t.tcp.Seq
+		packetInfo.TCPAckNr = t.tcp.Ack
+		packetInfo.TCPWindow = t.tcp.Window
+		if t.tcp.SYN {
+			packetInfo.TCPOptions = getTCPOptions(t.tcp.Options)
+		}
+		// Data offset in 32 bits words. Malformed packets with a header exceeding the IP length have no payload.
+		if tcpHeaderLength := uint32(t.tcp.DataOffset) * 4; ipLength > tcpHeaderLength {
+			packetInfo.PayloadLength = ipLength - tcpHeaderLength
+		} else {
+			packetInfo.PayloadLength = 0
+		}
+		packetInfo.FlowKey = getTunnelFlowKey(GetFlowKey(packetInfo.SrcIP, packetInfo.DstIP, flows.TCP, packetInfo.SrcPort, packetInfo.DstPort), packetInfo)
+	case layers.LayerTypeUDP:
+		packetInfo.HasUDP = true
+		packetInfo.SrcPort = uint16(t.udp.SrcPort)
+		packetInfo.DstPort = uint16(t.udp.DstPort)
+		packetInfo.PayloadLength = uint32(t.udp.Length)
+		// The UDP length of jumbograms is zero (RFC 2675)
+		if t.udp.Length == 0 {
+			packetInfo.PayloadLength = ipLength
+		}
+		packetInfo.FlowKey = getTunnelFlowKey(GetFlowKey(packetInfo.SrcIP, packetInfo.DstIP, flows.UDP, packetInfo.SrcPort, packetInfo.DstPort), packetInfo)
+		if p.quicPorts[packetInfo.SrcPort] || p.quicPorts[packetInfo.DstPort] {
+			packetInfo.QUIC = parseQUICHeader(t.udp.Payload)
+			packetInfo.HasQUIC = packetInfo.QUIC != nil
+		}
+	case layers.LayerTypeSCTP:
+		packetInfo.HasSCTP = true
+		packetInfo.SCTPINIT = t.sctp.INIT
+		packetInfo.SCTPINITACK = t.sctp.INITACK
+		packetInfo.SCTPABORT = t.sctp.ABORT
+		packetInfo.SCTPSHUTDOWN = t.sctp.SHUTDOWN
+		packetInfo.SrcPort = t.sctp.SrcPort
+		packetInfo.DstPort = t.sctp.DstPort
+		packetInfo.PayloadLength = t.sctp.PayloadLength
+		packetInfo.FlowKey = getTunnelFlowKey(GetFlowKey(packetInfo.SrcIP, packetInfo.DstIP, flows.SCTP, packetInfo.SrcPort, packetInfo.DstPort), packetInfo)
+	case layers.LayerTypeICMPv4:
+		packetInfo.HasICMP = true
+		packetInfo.ICMPType, packetInfo.ICMPReply = getICMPv4RequestType(t.icmp4.TypeCode.Type())
+		packetInfo.ICMPCode = t.icmp4.TypeCode.Code()
+		if isICMPv4Query(packetInfo.ICMPType) {
+			packetInfo.ICMPIdentifier = t.icmp4.Id
+		}
+		setICMPPacketInformation(packetInfo, ipLength, flows.ICMP)
+	case layers.LayerTypeICMPv6:
+		packetInfo.HasICMP = true
+		packetInfo.ICMPv6 = true
+		packetInfo.ICMPType, packetInfo.ICMPReply = getICMPv6RequestType(t.icmp6.TypeCode.Type())
+		packetInfo.ICMPCode = t.icmp6.TypeCode.Code()
+		setICMPPacketInformation(packetInfo, ipLength, flows.ICMPv6)
+	case layers.LayerTypeICMPv6Echo:
+		// Follows the ICMPv6 layer, the identifier is only known now
+		packetInfo.ICMPIdentifier = t.icmp6echo.Identifier
+		setICMPPacketInformation(packetInfo, ipLength, flows.ICMPv6)
+	}
+}
+
+// getTCPOptions extracts the options negotiated during the handshake. Options with an invalid length are ignored.
+func getTCPOptions(options []layers.TCPOption) (tcpOptions flows.TCPOptions) {
+	for _, option := range options {
+		switch option.OptionType {
+		case layers.TCPOptionKindMSS:
+			if len(option.OptionData) == 2 {
+				tcpOptions.MSS = binary.BigEndian.Uint16(option.OptionData)
+			}
+		case layers.TCPOptionKindWindowScale:
+			if len(option.OptionData) == 1 {
+				tcpOptions.WindowScale = option.OptionData[0]
+				tcpOptions.HasWindowScale = true
+			}
+		case layers.TCPOptionKindSACKPermitted:
+			tcpOptions.SACKPermitted = true
+		case layers.TCPOptionKindTimestamps:
+			tcpOptions.Timestamps = len(option.OptionData) == 8
+		}
+	}
+	return tcpOptions
+}
+
 // flushRingbuffer checks if packets can be flushed out to the processing unit.
 func (p *Parser) flushRingbuffer() {
 	for range p.ringbufferFlushChannel {
@@ -218,13 +364,17 @@ func (p *Parser) flushRingbuffer() {
 		for i := p.ringbufferStart; true; i++ {
 			ringBufferIndex := i % p.ringbufferSize
 			if !p.ringbufferUsedlist[ringBufferIndex] {
-				p.ringbufferStart = i
+				atomic.StoreInt64(&p.ringbufferStart, i)
 				break
 			}
-			if p.ringbuffer[ringBufferIndex].HasTCP {
-				p.pool.AddTCPPacket(&p.ringbuffer[ringBufferIndex])
-			} else if p.ringbuffer[ringBufferIndex].HasUDP {
-				p.pool.AddUDPPacket(&p.ringbuffer[ringBufferIndex])
+			packetInfo := &p.ringbuffer[ringBufferIndex]
+			if packetInfo.Fragment != nil {
+				// Only flush if the datagram is complete
+				if p.reassembler.addFragment(packetInfo) {
+					p.addToPool(packetInfo)
+				}
+			} else {
+				p.addToPool(packetInfo)
 			}
 			p.ringbufferUsedlist[ringBufferIndex] = false
 		}
@@ -232,6 +382,55 @@ func (p *Parser) flushRingbuffer() {
 	p.wgRingbufferFlush.Done()
 }
 
+// addToPool adds the packet to the pool of its transport protocol if its flow is part of the sample
+func (p *Parser) addToPool(packetInfo *flows.PacketInformation) {
+	if packetInfo.HasQUIC {
+		packetInfo.HasQUIC = p.quicTracker.setFlowKey(packetInfo)
+	}
+	// Sampling after reassembly and after the FlowKey of QUIC packets is replaced by the FlowKey of their connection,
+	// otherwise connections spanning several 5-tuples are only sampled partially
+	p.sample(packetInfo)
+	if packetInfo.HasTCP {
+		p.pool.AddTCPPacket(packetInfo)
+	} else if packetInfo.HasUDP {
+		p.pool.AddUDPPacket(packetInfo)
+	} else if packetInfo.HasSCTP {
+		p.pool.AddSCTPPacket(packetInfo)
+	} else if packetInfo.HasICMP {
+		p.pool.AddICMPPacket(packetInfo)
+	}
+}
+
+// sample removes the packet (by resetting its protocol) if its flow is not part of the sample
+func (p *Parser) sample(packetInfo *flows.PacketInformation) {
+	if uint64(packetInfo.FlowKey)%p.samplingModulo > p.numFlowThreads {
+		packetInfo.HasTCP = false
+		packetInfo.HasUDP = false
+		packetInfo.HasICMP = false
+		packetInfo.HasSCTP = false
+		packetInfo.HasQUIC = false
+		packetInfo.QUIC = nil
+	}
+}
+
+// newIPFragment creates a fragment. The data is copied, since the packet data is not required anymore after parsing.
+func newIPFragment(data []byte, offset, length, identification uint32, protocol uint8, moreFragments, isIPv6 bool) *flows.IPFragment {
+	if uint32(len(data)) > length {
+		data = data[:length]
+	}
+	fragment := &flows.IPFragment{
+		Data:           make([]byte, len(data)),
+		Offset:         offset,
+		Length:         length,
+		Identification: identification,
+		Protocol:       protocol,
+		MoreFragments:  moreFragments,
+		IPv6:           isIPv6,
+	}
+	copy(fragment.Data, data)
+	return fragment
+}
+
 // GetFlowKey returns the Flow key. Is symmetric so A:46254<-->B:80 returns the same key in both directions
 func GetFlowKey(srcIP, dstIP uint64, protocol uint8, srcPort, dstPort uint16) flows.FlowKeyType {
 	var app = make([]byte, 10)
@@ -244,3 +443,75 @@ func GetFlowKey(srcIP, dstIP uint64, protocol uint8, srcPort, dstPort uint16) fl
 	var hashDst = xxhash.Sum64(app)
 	return flows.FlowKeyType(hashSrc + uint64(protocol) + hashDst)
 }
+
+// getTunnelFlowKey mixes the outermost tunnel of the packet into the flow key,
+// so that inner traffic with the same 5-tuple in different tunnels (e.g. VXLAN VNIs, GTP-U TEIDs or MPLS labels) belongs to different flows
+func getTunnelFlowKey(flowKey flows.FlowKeyType, packetInfo *flows.PacketInformation) flows.FlowKeyType {
+	if packetInfo.TunnelType == flows.TunnelNone {
+		return flowKey
+	}
+	var app = make([]byte, 5)
+	app[0] = packetInfo.TunnelType
+	binary.LittleEndian.PutUint32(app[1:], packetInfo.TunnelID)
+	return flowKey ^ flows.FlowKeyType(xxhash.Sum64(app))
+}
+
+// icmpHeaderLength is the length of the ICMP header including identifier and sequence number (or the unused field)
+const icmpHeaderLength = 8
+
+// setICMPPacketInformation sets ports, payload length and flow key of an ICMP or ICMPv6 packet.
+// Both ports are set to the request type, so requests and replies are mapped onto the same flow.
+func setICMPPacketInformation(packetInfo *flows.PacketInformation, ipLength uint32, protocol uint8) {
+	packetInfo.SrcPort = uint16(packetInfo.ICMPType)
+	packetInfo.DstPort = uint16(packetInfo.ICMPType)
+	if ipLength > icmpHeaderLength {
+		packetInfo.PayloadLength = ipLength - icmpHeaderLength
+	} else {
+		packetInfo.PayloadLength = 0
+	}
+	packetInfo.FlowKey = getTunnelFlowKey(GetICMPFlowKey(packetInfo.SrcIP, packetInfo.DstIP, protocol,
+		packetInfo.ICMPType, packetInfo.ICMPCode, packetInfo.ICMPIdentifier), packetInfo)
+}
+
+// getICMPv4RequestType returns the type of the request belonging to the ICMP type and whether the type is a reply.
+// Types which are not part of a request/reply exchange (e.g. destination unreachable) are returned unchanged.
+func getICMPv4RequestType(icmpType uint8) (requestType uint8, reply bool) {
+	switch icmpType {
+	case layers.ICMPv4TypeEchoReply:
+		return layers.ICMPv4TypeEchoRequest, true
+	case layers.ICMPv4TypeTimestampReply, layers.ICMPv4TypeInfoReply, layers.ICMPv4TypeAddressMaskReply:
+		return icmpType - 1, true
+	default:
+		return icmpType, false
+	}
+}
+
+// isICMPv4Query returns whether the ICMP request type carries an identifier
+func isICMPv4Query(requestType uint8) bool {
+	switch requestType {
+	case layers.ICMPv4TypeEchoRequest, layers.ICMPv4TypeTimestampRequest,
+		layers.ICMPv4TypeInfoRequest, layers.ICMPv4TypeAddressMaskRequest:
+		return true
+	default:
+		return false
+	}
+}
+
+// getICMPv6RequestType returns the type of the request belonging to the ICMPv6 type and whether the type is a reply.
+// Types which are not part of a request/reply exchange (e.g. packet too big) are returned unchanged.
+func getICMPv6RequestType(icmpType uint8) (requestType uint8, reply bool) {
+	if icmpType == layers.ICMPv6TypeEchoReply {
+		return layers.ICMPv6TypeEchoRequest, true
+	}
+	return icmpType, false
+}
+
+// GetICMPFlowKey returns the Flow key of an ICMP message. Is symmetric, so an echo request and its reply return the same key.
+// icmpType must be the type of the request.
+func GetICMPFlowKey(srcIP, dstIP uint64, protocol uint8, icmpType, icmpCode uint8, identifier uint16) flows.FlowKeyType {
+	var app = make([]byte, 4)
+	app[0] = icmpType
+	app[1] = icmpCode
+	binary.LittleEndian.PutUint16(app[2:], identifier)
+	return GetFlowKey(srcIP, dstIP, protocol, 0, 0) ^ flows.FlowKeyType(xxhash.Sum64(app))
+}
@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"scalable-flow-analyzer/flows"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+func TestTCPPayloadLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		ipLength      uint32
+		dataOffset    uint8
+		payloadLength uint32
+	}{
+		{name: "payload", ipLength: 120, dataOffset: 5, payloadLength: 100},
+		{name: "options", ipLength: 120, dataOffset: 8, payloadLength: 88},
+		{name: "no payload", ipLength: 20, dataOffset: 5, payloadLength: 0},
+		{name: "header exceeds IP length", ipLength: 12, dataOffset: 5, payloadLength: 0},
+	}
+
+	p := &Parser{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var packetInfo flows.PacketInformation
+			transport := transportLayers{tcp: layers.TCP{DataOffset: test.dataOffset}}
+			p.setTransportInformation(&packetInfo, layers.LayerTypeTCP, test.ipLength, &transport)
+			if packetInfo.PayloadLength != test.payloadLength {
+				t.Errorf("PayloadLength = %d, want %d", packetInfo.PayloadLength, test.payloadLength)
+			}
+		})
+	}
+}
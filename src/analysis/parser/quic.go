@@ -0,0 +1,174 @@
+package parser
+
+// This file contains the QUIC header parsing and the connection ID tracking.
+// QUIC connections can change their 5-tuple (e.g. NAT rebinding), therefore QUIC flows are identified by their connection IDs.
+// Note: A client migrating deliberately switches to a new connection ID, which was announced encrypted (NEW_CONNECTION_ID frame).
+// Such a migration cannot be followed passively and results in a new flow.
+
+import (
+	"scalable-flow-analyzer/flows"
+	"encoding/binary"
+	"time"
+
+	"github.com/cespare/xxhash"
+)
+
+// quicTrackerCleanupInterval defines how often (relative to packet timestamps) idle connection IDs are removed
+const quicTrackerCleanupInterval = int64(1 * time.Minute)
+
+const quicLongHeaderForm = 0x80
+const quicFixedBit = 0x40
+
+// parseQUICHeader parses the unprotected part of a QUIC long or short header (RFC 8999, RFC 9000).
+// Returns nil if the payload is not a QUIC packet.
+func parseQUICHeader(payload []byte) *flows.QUICHeader {
+	if len(payload) < 1 {
+		return nil
+	}
+	header := &flows.QUICHeader{}
+	if payload[0]&quicLongHeaderForm == 0 {
+		// Short header: Only the destination connection ID follows, its length is only known to the endpoints
+		// Any UDP payload may look like a short header, the quicTracker only accepts it for known connections
+		if payload[0]&quicFixedBit == 0 {
+			return nil
+		}
+		header.DstConnIDLength = uint8(copy(header.DstConnID[:], payload[1:]))
+		return header
+	}
+
+	// Long header: flags (1), version (4), DCID length (1), DCID, SCID length (1), SCID
+	header.LongHeader = true
+	if len(payload) < 7 {
+		return nil
+	}
+	version := binary.BigEndian.Uint32(payload[1:5])
+	// The fixed bit is not set for version negotiation packets (version 0)
+	if version != 0 && payload[0]&quicFixedBit == 0 {
+		return nil
+	}
+	dstConnIDLength := int(payload[5])
+	if dstConnIDLength > flows.QUICMaxConnIDLength || len(payload) < 7+dstConnIDLength {
+		return nil
+	}
+	copy(header.DstConnID[:], payload[6:6+dstConnIDLength])
+	header.DstConnIDLength = uint8(dstConnIDLength)
+
+	srcConnIDLength := int(payload[6+dstConnIDLength])
+	if srcConnIDLength > flows.QUICMaxConnIDLength || len(payload) < 7+dstConnIDLength+srcConnIDLength {
+		return nil
+	}
+	copy(header.SrcConnID[:], payload[7+dstConnIDLength:7+dstConnIDLength+srcConnIDLength])
+	header.SrcConnIDLength = uint8(srcConnIDLength)
+	return header
+}
+
+type quicConnection struct {
+	flowKey  flows.FlowKeyType
+	lastSeen int64
+}
+
+// quicTracker maps connection IDs to the FlowKey of the QUIC connection.
+// It is not thread safe and must be called in packet order (from flushRingbuffer).
+type quicTracker struct {
+	// Hash of a connection ID -> QUIC connection
+	connIDs map[uint64]*quicConnection
+	// FlowKey of the 5-tuple -> QUIC connection. Required for zero length connection IDs and short headers
+	fiveTuples map[flows.FlowKeyType]*quicConnection
+	// Connection ID lengths seen in long headers. Used to find the connection ID in short headers.
+	connIDLengths []int
+	lastCleanup   int64
+}
+
+func newQUICTracker() *quicTracker {
+	return &quicTracker{
+		connIDs:    make(map[uint64]*quicConnection),
+		fiveTuples: make(map[flows.FlowKeyType]*quicConnection),
+	}
+}
+
+// setFlowKey replaces the FlowKey of the packet (based on the 5-tuple) by the FlowKey of its QUIC connection.
+// The FlowKey of a connection is the 5-tuple FlowKey of the first packet seen.
+// Short headers are easily matched by other UDP payloads, therefore they are only accepted on 5-tuples already
+// seen with a long header or if their connection ID matches a known connection. Returns false if the packet is not accepted as QUIC.
+func (qt *quicTracker) setFlowKey(packetInfo *flows.PacketInformation) bool {
+	header := packetInfo.QUIC
+	// The header is not required anymore, release it
+	packetInfo.QUIC = nil
+
+	var connection *quicConnection
+	if header.LongHeader {
+		connection = qt.lookup(header.DstConnID[:header.DstConnIDLength])
+		if connection == nil {
+			connection = qt.lookup(header.SrcConnID[:header.SrcConnIDLength])
+		}
+		if connection == nil {
+			connection = qt.fiveTuples[packetInfo.FlowKey]
+		}
+		if connection == nil {
+			connection = &quicConnection{flowKey: packetInfo.FlowKey}
+		}
+	} else {
+		// The connection of the 5-tuple takes precedence, the connection ID is only needed after a migration
+		connection = qt.fiveTuples[packetInfo.FlowKey]
+		for _, length := range qt.connIDLengths {
+			if connection != nil {
+				break
+			}
+			if length <= int(header.DstConnIDLength) {
+				connection = qt.lookup(header.DstConnID[:length])
+			}
+		}
+		if connection == nil {
+			return false
+		}
+	}
+	connection.lastSeen = packetInfo.Timestamp
+
+	qt.fiveTuples[packetInfo.FlowKey] = connection
+	if header.LongHeader {
+		// Both connection IDs are used in short headers later on
+		qt.register(header.DstConnID[:header.DstConnIDLength], connection)
+		qt.register(header.SrcConnID[:header.SrcConnIDLength], connection)
+	}
+	packetInfo.FlowKey = connection.flowKey
+
+	if packetInfo.Timestamp-qt.lastCleanup > quicTrackerCleanupInterval {
+		qt.cleanup(packetInfo.Timestamp)
+	}
+	return true
+}
+
+func (qt *quicTracker) lookup(connID []byte) *quicConnection {
+	if len(connID) == 0 {
+		return nil
+	}
+	return qt.connIDs[xxhash.Sum64(connID)]
+}
+
+func (qt *quicTracker) register(connID []byte, connection *quicConnection) {
+	if len(connID) == 0 {
+		return
+	}
+	qt.connIDs[xxhash.Sum64(connID)] = connection
+	for _, length := range qt.connIDLengths {
+		if length == len(connID) {
+			return
+		}
+	}
+	qt.connIDLengths = append(qt.connIDLengths, len(connID))
+}
+
+// cleanup removes all connections which have been idle longer than the UDP timeout
+func (qt *quicTracker) cleanup(currentTime int64) {
+	qt.lastCleanup = currentTime
+	for key, connection := range qt.connIDs {
+		if currentTime-connection.lastSeen > flows.UDPTimeout {
+			delete(qt.connIDs, key)
+		}
+	}
+	for key, connection := range qt.fiveTuples {
+		if currentTime-connection.lastSeen > flows.UDPTimeout {
+			delete(qt.fiveTuples, key)
+		}
+	}
+}
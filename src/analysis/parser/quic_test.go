@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"scalable-flow-analyzer/flows"
+	"testing"
+)
+
+func TestQUICTrackerShortHeader(t *testing.T) {
+	connID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	longHeader := append([]byte{0xc0, 0, 0, 0, 1, byte(len(connID))}, connID...)
+	longHeader = append(longHeader, 0)
+	shortHeader := append([]byte{0x40}, connID...)
+	otherShortHeader := []byte{0x40, 9, 9, 9, 9, 9, 9, 9, 9}
+
+	tests := []struct {
+		name     string
+		flowKey  flows.FlowKeyType
+		payload  []byte
+		accepted bool
+		wantKey  flows.FlowKeyType
+	}{
+		{name: "unknown short header", flowKey: 1, payload: shortHeader, accepted: false, wantKey: 1},
+		{name: "long header", flowKey: 2, payload: longHeader, accepted: true, wantKey: 2},
+		{name: "short header on known 5-tuple", flowKey: 2, payload: otherShortHeader, accepted: true, wantKey: 2},
+		{name: "migrated short header", flowKey: 3, payload: shortHeader, accepted: true, wantKey: 2},
+		{name: "short header after migration", flowKey: 3, payload: otherShortHeader, accepted: true, wantKey: 2},
+		{name: "unrelated short header", flowKey: 4, payload: otherShortHeader, accepted: false, wantKey: 4},
+	}
+
+	qt := newQUICTracker()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			packetInfo := flows.PacketInformation{FlowKey: test.flowKey, QUIC: parseQUICHeader(test.payload)}
+			if packetInfo.QUIC == nil {
+				t.Fatal("parseQUICHeader returned nil")
+			}
+			if accepted := qt.setFlowKey(&packetInfo); accepted != test.accepted {
+				t.Errorf("setFlowKey = %t, want %t", accepted, test.accepted)
+			}
+			if packetInfo.FlowKey != test.wantKey {
+				t.Errorf("FlowKey = %d, want %d", packetInfo.FlowKey, test.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package parser
+
+// This file contains the optional IP fragment reassembly.
+// The parser workers extract the fragments (flows.IPFragment) and store them in the sorting ringbuffer like any other packet.
+// The fragments are reassembled when they leave the ringbuffer, since the parser workers process packets out of order
+// and waiting for missing fragments in the workers would block the ringbuffer.
+// Reassembled datagrams are decoded like unfragmented packets and added to the pool at the position of the last fragment.
+
+import (
+	"scalable-flow-analyzer/flows"
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+type fragmentKey struct {
+	srcIP          uint64
+	dstIP          uint64
+	identification uint32
+	protocol       uint8
+	ipv6           bool
+}
+
+type fragmentedDatagram struct {
+	key            fragmentKey
+	fragments      []*flows.IPFragment
+	firstTimestamp int64
+	receivedLength uint32 // Sum of the lengths of all fragments
+	totalLength    uint32 // Known once the last fragment (no more fragments flag) is received
+	lastReceived   bool
+	memory         int
+}
+
+// reassembler reassembles IPv4 and IPv6 fragments. Its memory usage is bounded by maxMemory (bytes of fragment data).
+// It is not thread safe and must be called in packet order (from flushRingbuffer).
+type reassembler struct {
+	parser     *Parser
+	datagrams  map[fragmentKey]*fragmentedDatagram
+	queue      []*fragmentedDatagram // Datagrams ordered by their first fragment, used for timeouts and eviction
+	timeout    int64
+	maxMemory  int
+	memoryUsed int
+	transport  transportLayers
+	decoders   map[gopacket.LayerType]*gopacket.DecodingLayerParser
+	decoded    []gopacket.LayerType
+
+	// Statistics
+	numFragments   int64
+	numReassembled int64
+	numIncomplete  int64 // Datagrams which timed out or were evicted due to the memory limit
+	numOverlapping int64 // Datagrams dropped, since their fragments overlapped
+}
+
+func newReassembler(parser *Parser, timeout int64, maxMemory int) *reassembler {
+	return &reassembler{
+		parser:    parser,
+		datagrams: make(map[fragmentKey]*fragmentedDatagram),
+		timeout:   timeout,
+		maxMemory: maxMemory,
+		decoders:  make(map[gopacket.LayerType]*gopacket.DecodingLayerParser),
+	}
+}
+
+// addFragment adds the fragment of the packet. If the datagram is complete, the packet information
+// is replaced by the information of the reassembled datagram and true is returned.
+func (r *reassembler) addFragment(packetInfo *flows.PacketInformation) bool {
+	fragment := packetInfo.Fragment
+	// The fragment is not required in the ringbuffer anymore, release it
+	packetInfo.Fragment = nil
+	r.numFragments++
+	r.expire(packetInfo.Timestamp)
+
+	key := fragmentKey{
+		srcIP:          packetInfo.SrcIP,
+		dstIP:          packetInfo.DstIP,
+		identification: fragment.Identification,
+		protocol:       fragment.Protocol,
+		ipv6:           fragment.IPv6,
+	}
+	datagram, exists := r.datagrams[key]
+	if !exists {
+		datagram = &fragmentedDatagram{key: key, firstTimestamp: packetInfo.Timestamp}
+		r.datagrams[key] = datagram
+		r.queue = append(r.queue, datagram)
+	}
+
+	// Check for duplicates and overlaps
+	for _, existing := range datagram.fragments {
+		if existing.Offset == fragment.Offset && existing.Length == fragment.Length {
+			// Duplicate (e.g. captured twice)
+			return false
+		}
+		if fragment.Offset < existing.Offset+existing.Length && existing.Offset < fragment.Offset+fragment.Length {
+			// Overlapping fragments are dropped (RFC 5722), this is also done for IPv4 since they are typically malicious
+			r.numOverlapping++
+			r.remove(datagram)
+			return false
+		}
+	}
+
+	// Bound memory usage: evict the oldest datagrams, except the datagram of the fragment
+	for i := 0; r.memoryUsed+len(fragment.Data) > r.maxMemory && i < len(r.queue); {
+		oldest := r.queue[i]
+		switch {
+		case r.datagrams[oldest.key] != oldest:
+			// Already removed
+			r.queue = append(r.queue[:i], r.queue[i+1:]...)
+		case oldest == datagram:
+			i++
+		default:
+			// Its entry remains in the queue (unless it is the head) and is skipped in the next iteration
+			r.numIncomplete++
+			r.remove(oldest)
+		}
+	}
+	if r.memoryUsed+len(fragment.Data) > r.maxMemory {
+		// Datagram alone exceeds the memory limit
+		r.numIncomplete++
+		r.remove(datagram)
+		return false
+	}
+
+	datagram.fragments = append(datagram.fragments, fragment)
+	datagram.receivedLength += fragment.Length
+	datagram.memory += len(fragment.Data)
+	r.memoryUsed += len(fragment.Data)
+	if !fragment.MoreFragments {
+		datagram.lastReceived = true
+		datagram.totalLength = fragment.Offset + fragment.Length
+	}
+
+	if !datagram.lastReceived || datagram.receivedLength != datagram.totalLength {
+		return false
+	}
+
+	// Datagram complete, fragments behind the last fragment are invalid
+	for _, f := range datagram.fragments {
+		if f.Offset+f.Length > datagram.totalLength {
+			r.numIncomplete++
+			r.remove(datagram)
+			return false
+		}
+	}
+	r.numReassembled++
+	data := make([]byte, datagram.totalLength)
+	for _, f := range datagram.fragments {
+		copy(data[f.Offset:], f.Data)
+	}
+	r.remove(datagram)
+	r.decode(packetInfo, data, layers.IPProtocol(key.protocol).LayerType())
+	return true
+}
+
+// decode sets the transport information of the reassembled datagram
+func (r *reassembler) decode(packetInfo *flows.PacketInformation, data []byte, layerType gopacket.LayerType) {
+	decoder, exists := r.decoders[layerType]
+	if !exists {
+		decoder = gopacket.NewDecodingLayerParser(layerType,
+			&r.transport.tcp, &r.transport.udp, &r.transport.sctp,
+			&r.transport.icmp4, &r.transport.icmp6, &r.transport.icmp6echo)
+		r.decoders[layerType] = decoder
+	}
+	_ = decoder.DecodeLayers(data, &r.decoded)
+	for _, decodedLayerType := range r.decoded {
+		r.parser.setTransportInformation(packetInfo, decodedLayerType, uint32(len(data)), &r.transport)
+	}
+}
+
+// expire removes all datagrams which are older than the timeout
+func (r *reassembler) expire(currentTime int64) {
+	for len(r.queue) > 0 {
+		datagram := r.queue[0]
+		if r.datagrams[datagram.key] != datagram {
+			// Already removed
+			r.queue = r.queue[1:]
+			continue
+		}
+		if currentTime-datagram.firstTimestamp <= r.timeout {
+			return
+		}
+		r.numIncomplete++
+		r.remove(datagram)
+	}
+}
+
+func (r *reassembler) remove(datagram *fragmentedDatagram) {
+	if r.datagrams[datagram.key] == datagram {
+		delete(r.datagrams, datagram.key)
+		r.memoryUsed -= datagram.memory
+	}
+	if len(r.queue) > 0 && r.queue[0] == datagram {
+		r.queue = r.queue[1:]
+	}
+}
+
+// close counts all remaining datagrams as incomplete
+func (r *reassembler) close() {
+	r.numIncomplete += int64(len(r.datagrams))
+	r.datagrams = make(map[fragmentKey]*fragmentedDatagram)
+	r.queue = nil
+	r.memoryUsed = 0
+}
+
+func (r *reassembler) printStatistics() {
+	fmt.Println("Number of IP fragments:\t\t", humanize.Comma(r.numFragments))
+	fmt.Println("Reassembled datagrams:\t\t", humanize.Comma(r.numReassembled))
+	fmt.Println("Incomplete datagrams:\t\t", humanize.Comma(r.numIncomplete))
+	fmt.Println("Overlapping datagrams (dropped):", humanize.Comma(r.numOverlapping))
+}
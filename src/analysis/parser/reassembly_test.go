@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"scalable-flow-analyzer/flows"
+	"encoding/binary"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+// testDatagramLength is the length of the fragmented UDP datagrams (header and payload)
+const testDatagramLength = 64
+
+// testFragment is a fragment of the datagram with the identification, added at the timestamp
+type testFragment struct {
+	identification uint32
+	offset         uint32
+	length         uint32
+	timestamp      int64
+	complete       bool // Expected result of addFragment
+}
+
+// newTestFragment returns the packet information of a fragment of a UDP datagram from port 1234 to port 53
+func newTestFragment(fragment testFragment) *flows.PacketInformation {
+	datagram := make([]byte, testDatagramLength)
+	binary.BigEndian.PutUint16(datagram[0:2], 1234)
+	binary.BigEndian.PutUint16(datagram[2:4], 53)
+	binary.BigEndian.PutUint16(datagram[4:6], testDatagramLength)
+	data := datagram[fragment.offset : fragment.offset+fragment.length]
+	return &flows.PacketInformation{
+		Timestamp: fragment.timestamp,
+		SrcIP:     1,
+		DstIP:     2,
+		Fragment: newIPFragment(data, fragment.offset, fragment.length, fragment.identification, uint8(layers.IPProtocolUDP),
+			fragment.offset+fragment.length < testDatagramLength, false),
+	}
+}
+
+func TestReassembly(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeout        int64
+		maxMemory      int
+		fragments      []testFragment
+		numIncomplete  int64
+		numOverlapping int64
+	}{
+		{
+			name:      "in order",
+			fragments: []testFragment{{1, 0, 16, 0, false}, {1, 16, 16, 1, false}, {1, 32, 32, 2, true}},
+		},
+		{
+			name:      "out of order",
+			fragments: []testFragment{{1, 32, 32, 0, false}, {1, 0, 16, 1, false}, {1, 16, 16, 2, true}},
+		},
+		{
+			name:      "duplicate",
+			fragments: []testFragment{{1, 0, 32, 0, false}, {1, 0, 32, 1, false}, {1, 32, 32, 2, true}},
+		},
+		{
+			name:           "overlap",
+			fragments:      []testFragment{{1, 0, 32, 0, false}, {1, 16, 32, 1, false}, {1, 32, 32, 2, false}},
+			numOverlapping: 1,
+		},
+		{
+			name:      "interleaved datagrams",
+			fragments: []testFragment{{1, 0, 32, 0, false}, {2, 32, 32, 1, false}, {2, 0, 32, 2, true}, {1, 32, 32, 3, true}},
+		},
+		{
+			name:          "timeout",
+			timeout:       10,
+			fragments:     []testFragment{{1, 0, 32, 0, false}, {1, 32, 32, 20, false}},
+			numIncomplete: 1,
+		},
+		{
+			name:          "memory limit evicts the oldest datagram",
+			maxMemory:     64,
+			fragments:     []testFragment{{1, 0, 32, 0, false}, {2, 0, 32, 1, false}, {3, 0, 32, 2, false}, {3, 32, 32, 3, true}},
+			numIncomplete: 2,
+		},
+		{
+			name:          "memory limit evicts datagrams behind the current one",
+			maxMemory:     64,
+			fragments:     []testFragment{{1, 0, 32, 0, false}, {2, 0, 32, 1, false}, {1, 32, 32, 2, true}},
+			numIncomplete: 1,
+		},
+		{
+			name:      "memory limit skips removed datagrams",
+			maxMemory: 64,
+			fragments: []testFragment{{1, 0, 16, 0, false}, {2, 0, 16, 1, false}, {3, 0, 16, 2, false},
+				{2, 8, 16, 3, false}, {4, 0, 56, 4, false}},
+			numIncomplete:  2,
+			numOverlapping: 1,
+		},
+		{
+			name:          "datagram exceeds the memory limit",
+			maxMemory:     16,
+			fragments:     []testFragment{{1, 0, 32, 0, false}},
+			numIncomplete: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			timeout, maxMemory := test.timeout, test.maxMemory
+			if timeout == 0 {
+				timeout = 1000
+			}
+			if maxMemory == 0 {
+				maxMemory = 1 << 20
+			}
+			r := newReassembler(&Parser{}, timeout, maxMemory)
+			var numReassembled int64
+			for i, fragment := range test.fragments {
+				packetInfo := newTestFragment(fragment)
+				complete := r.addFragment(packetInfo)
+				if complete != fragment.complete {
+					t.Fatalf("fragment %d: addFragment = %t, want %t", i, complete, fragment.complete)
+				}
+				if !complete {
+					continue
+				}
+				numReassembled++
+				if !packetInfo.HasUDP || packetInfo.SrcPort != 1234 || packetInfo.DstPort != 53 || packetInfo.PayloadLength != testDatagramLength {
+					t.Errorf("fragment %d: reassembled datagram is not decoded: %+v", i, packetInfo)
+				}
+			}
+			if r.numReassembled != numReassembled {
+				t.Errorf("numReassembled = %d, want %d", r.numReassembled, numReassembled)
+			}
+			if r.numIncomplete != test.numIncomplete {
+				t.Errorf("numIncomplete = %d, want %d", r.numIncomplete, test.numIncomplete)
+			}
+			if r.numOverlapping != test.numOverlapping {
+				t.Errorf("numOverlapping = %d, want %d", r.numOverlapping, test.numOverlapping)
+			}
+			// The memory of removed datagrams is released
+			memory := 0
+			for _, datagram := range r.datagrams {
+				memory += datagram.memory
+			}
+			if r.memoryUsed != memory || r.memoryUsed > maxMemory {
+				t.Errorf("memoryUsed = %d, but the datagrams use %d bytes", r.memoryUsed, memory)
+			}
+		})
+	}
+}
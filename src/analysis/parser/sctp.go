@@ -0,0 +1,98 @@
+package parser
+
+// gopacket only provides a (slow) gopacket.Decoder for SCTP, which decodes every chunk into its own layer.
+// This file contains a minimal DecodingLayer, which only extracts the information needed for flow construction.
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// sctpCommonHeaderLength is the length of the SCTP common header (ports, verification tag, checksum)
+const sctpCommonHeaderLength = 12
+
+// sctpChunkHeaderLength is the length of the header every chunk starts with (type, flags, length)
+const sctpChunkHeaderLength = 4
+
+// sctpDataChunkHeaderLength is the length of a DATA chunk without user data
+const sctpDataChunkHeaderLength = 16
+
+// sctpIDataChunkHeaderLength is the length of an I-DATA chunk (RFC 8260) without user data
+const sctpIDataChunkHeaderLength = 20
+
+// sctpChunkTypeIData is the chunk type of I-DATA chunks (RFC 8260), which is unknown to gopacket
+const sctpChunkTypeIData layers.SCTPChunkType = 64
+
+// sctp is a gopacket.DecodingLayer for the SCTP common header, which walks all chunks of the packet.
+type sctp struct {
+	layers.BaseLayer
+	SrcPort       uint16
+	DstPort       uint16
+	PayloadLength uint32 // Sum of the user data of all DATA and I-DATA chunks
+	INIT          bool
+	INITACK       bool
+	ABORT         bool
+	SHUTDOWN      bool // Set for SHUTDOWN, SHUTDOWN ACK and SHUTDOWN COMPLETE chunks
+}
+
+// CanDecode returns the layer type this DecodingLayer can decode
+func (s *sctp) CanDecode() gopacket.LayerClass {
+	return layers.LayerTypeSCTP
+}
+
+// NextLayerType returns LayerTypeZero, since all chunks are handled by this layer
+func (s *sctp) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+// DecodeFromBytes decodes the common header and walks the chunk list.
+// Chunks are only evaluated as long as their header is part of the captured data.
+// The user data length is taken from the chunk header, so truncated packets (snaplen) still count their full size.
+func (s *sctp) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < sctpCommonHeaderLength {
+		df.SetTruncated()
+		return errors.New("SCTP packet too short")
+	}
+	s.SrcPort = binary.BigEndian.Uint16(data[0:2])
+	s.DstPort = binary.BigEndian.Uint16(data[2:4])
+	s.PayloadLength = 0
+	s.INIT = false
+	s.INITACK = false
+	s.ABORT = false
+	s.SHUTDOWN = false
+
+	for offset := sctpCommonHeaderLength; offset+sctpChunkHeaderLength <= len(data); {
+		chunkType := layers.SCTPChunkType(data[offset])
+		chunkLength := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		if chunkLength < sctpChunkHeaderLength {
+			// Malformed chunk, the following chunks cannot be found
+			break
+		}
+		switch chunkType {
+		case layers.SCTPChunkTypeData:
+			if chunkLength > sctpDataChunkHeaderLength {
+				s.PayloadLength += uint32(chunkLength - sctpDataChunkHeaderLength)
+			}
+		case sctpChunkTypeIData:
+			if chunkLength > sctpIDataChunkHeaderLength {
+				s.PayloadLength += uint32(chunkLength - sctpIDataChunkHeaderLength)
+			}
+		case layers.SCTPChunkTypeInit:
+			s.INIT = true
+		case layers.SCTPChunkTypeInitAck:
+			s.INITACK = true
+		case layers.SCTPChunkTypeAbort:
+			s.ABORT = true
+		case layers.SCTPChunkTypeShutdown, layers.SCTPChunkTypeShutdownAck, layers.SCTPChunkTypeShutdownComplete:
+			s.SHUTDOWN = true
+		}
+		// Chunks are padded to a multiple of 4 bytes
+		offset += (chunkLength + 3) &^ 3
+	}
+
+	s.BaseLayer = layers.BaseLayer{Contents: data[:sctpCommonHeaderLength], Payload: data[sctpCommonHeaderLength:]}
+	return nil
+}
@@ -0,0 +1,189 @@
+package parser
+
+// This file contains the decoding layers required for tunnel decapsulation.
+// Flows are built from the innermost IP header, the outermost tunnel is recorded in the packet information and separates flows (see getTunnelFlowKey).
+
+import (
+	"scalable-flow-analyzer/flows"
+	"encoding/binary"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// mplsLabelLength is the length of a single label stack entry
+const mplsLabelLength = 4
+
+// gtpMessageTypeGPDU is the GTP-U message type of encapsulated user data (all other types are signalling)
+const gtpMessageTypeGPDU = 255
+
+// tunnelDecapsulation defines which tunnel protocols are decapsulated
+type tunnelDecapsulation struct {
+	gre   bool
+	vxlan bool
+	gtpu  bool
+	mpls  bool
+	ipip  bool
+}
+
+// newTunnelDecapsulation parses the list of tunnel protocols (gre, vxlan, gtpu, mpls, ipip)
+func newTunnelDecapsulation(protocols []string) tunnelDecapsulation {
+	var decapsulation tunnelDecapsulation
+	for _, protocol := range protocols {
+		switch strings.ToLower(strings.TrimSpace(protocol)) {
+		case "":
+		case "gre":
+			decapsulation.gre = true
+		case "vxlan":
+			decapsulation.vxlan = true
+		case "gtpu", "gtp-u":
+			decapsulation.gtpu = true
+		case "mpls":
+			decapsulation.mpls = true
+		case "ipip":
+			decapsulation.ipip = true
+		default:
+			log.Fatalln("Unknown tunnel protocol:", protocol)
+		}
+	}
+	return decapsulation
+}
+
+// ipv4 is a gopacket.DecodingLayer for IPv4, which stops at encapsulated IP packets if IP-in-IP is not decapsulated.
+type ipv4 struct {
+	layers.IPv4
+	decapsulate bool
+}
+
+// NextLayerType returns the layer type of the upper layer
+func (i *ipv4) NextLayerType() gopacket.LayerType {
+	if !i.decapsulate && (i.Protocol == layers.IPProtocolIPv4 || i.Protocol == layers.IPProtocolIPv6) {
+		return gopacket.LayerTypeZero
+	}
+	return i.IPv4.NextLayerType()
+}
+
+// gtpu is a gopacket.DecodingLayer for GTP-U, which only decodes the payload of G-PDUs.
+type gtpu struct {
+	layers.GTPv1U
+}
+
+// NextLayerType returns the layer type of the encapsulated packet
+func (g *gtpu) NextLayerType() gopacket.LayerType {
+	if g.MessageType != gtpMessageTypeGPDU {
+		return gopacket.LayerTypeZero
+	}
+	return g.GTPv1U.NextLayerType()
+}
+
+// mpls is a gopacket.DecodingLayer for the complete MPLS label stack.
+// gopacket only provides a gopacket.Decoder for MPLS, which decodes every label into its own layer.
+type mpls struct {
+	layers.BaseLayer
+	Label uint32 // Top label
+}
+
+// CanDecode returns the layer type this DecodingLayer can decode
+func (m *mpls) CanDecode() gopacket.LayerClass {
+	return layers.LayerTypeMPLS
+}
+
+// NextLayerType guesses the encapsulated protocol by its IP version, since MPLS does not indicate it
+func (m *mpls) NextLayerType() gopacket.LayerType {
+	if len(m.Payload) == 0 {
+		return gopacket.LayerTypeZero
+	}
+	switch m.Payload[0] >> 4 {
+	case 4:
+		return layers.LayerTypeIPv4
+	case 6:
+		return layers.LayerTypeIPv6
+	default:
+		// e.g. pseudowires with control word
+		return gopacket.LayerTypeZero
+	}
+}
+
+// DecodeFromBytes decodes all labels until the bottom of stack
+func (m *mpls) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	offset := 0
+	for {
+		if len(data) < offset+mplsLabelLength {
+			df.SetTruncated()
+			return errors.New("MPLS label stack too short")
+		}
+		entry := binary.BigEndian.Uint32(data[offset : offset+mplsLabelLength])
+		if offset == 0 {
+			m.Label = entry >> 12
+		}
+		offset += mplsLabelLength
+		if entry&0x100 != 0 {
+			// Bottom of stack
+			break
+		}
+	}
+	m.BaseLayer = layers.BaseLayer{Contents: data[:offset], Payload: data[offset:]}
+	return nil
+}
+
+// tunnelLayers contains the decoding layers of all supported tunnel protocols
+type tunnelLayers struct {
+	gre   layers.GRE
+	vxlan layers.VXLAN
+	gtpu  gtpu
+	mpls  mpls
+}
+
+// getDecodingLayers returns the tunnel decoding layers which are enabled
+func (t *tunnelLayers) getDecodingLayers(decapsulation tunnelDecapsulation) []gopacket.DecodingLayer {
+	var decodingLayers []gopacket.DecodingLayer
+	if decapsulation.gre {
+		decodingLayers = append(decodingLayers, &t.gre)
+	}
+	if decapsulation.vxlan {
+		decodingLayers = append(decodingLayers, &t.vxlan)
+	}
+	if decapsulation.gtpu {
+		decodingLayers = append(decodingLayers, &t.gtpu)
+	}
+	if decapsulation.mpls {
+		decodingLayers = append(decodingLayers, &t.mpls)
+	}
+	return decodingLayers
+}
+
+// setTunnelInformation sets the outermost tunnel of the layers, which encapsulate the innermost IP header.
+// Note: gopacket reuses the layers, so for nested tunnels of the same protocol the ID of the inner one is recorded.
+func (t *tunnelLayers) setTunnelInformation(packetInfo *flows.PacketInformation, encapsulatingLayers []gopacket.LayerType) {
+	numIPLayers := 0
+	for _, layerType := range encapsulatingLayers {
+		switch layerType {
+		case layers.LayerTypeGRE:
+			packetInfo.TunnelType = flows.TunnelGRE
+			if t.gre.KeyPresent {
+				packetInfo.TunnelID = t.gre.Key
+			}
+		case layers.LayerTypeVXLAN:
+			packetInfo.TunnelType = flows.TunnelVXLAN
+			packetInfo.TunnelID = t.vxlan.VNI
+		case layers.LayerTypeGTPv1U:
+			packetInfo.TunnelType = flows.TunnelGTPU
+			packetInfo.TunnelID = t.gtpu.TEID
+		case layers.LayerTypeMPLS:
+			packetInfo.TunnelType = flows.TunnelMPLS
+			packetInfo.TunnelID = t.mpls.Label
+		case layers.LayerTypeIPv4, layers.LayerTypeIPv6:
+			numIPLayers++
+			continue
+		default:
+			continue
+		}
+		return
+	}
+	if numIPLayers > 0 {
+		packetInfo.TunnelType = flows.TunnelIPIP
+	}
+}
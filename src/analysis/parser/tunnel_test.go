@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"scalable-flow-analyzer/flows"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// craftIPv4Packet returns an IPv4 header from src to dst followed by the payload
+func craftIPv4Packet(protocol layers.IPProtocol, src, dst string, payload []byte) []byte {
+	header := make([]byte, 20)
+	header[0] = 0x45
+	binary.BigEndian.PutUint16(header[2:4], uint16(20+len(payload)))
+	header[8] = 64
+	header[9] = byte(protocol)
+	copy(header[12:16], net.ParseIP(src).To4())
+	copy(header[16:20], net.ParseIP(dst).To4())
+	return append(header, payload...)
+}
+
+// craftUDPPacket returns a UDP header to the port followed by the payload
+func craftUDPPacket(dstPort uint16, payload []byte) []byte {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint16(header[0:2], 50000)
+	binary.BigEndian.PutUint16(header[2:4], dstPort)
+	binary.BigEndian.PutUint16(header[4:6], uint16(8+len(payload)))
+	return append(header, payload...)
+}
+
+// craftEthernetFrame returns an Ethernet header with the EtherType followed by the payload
+func craftEthernetFrame(etherType layers.EthernetType, payload []byte) []byte {
+	header := make([]byte, 14)
+	binary.BigEndian.PutUint16(header[12:14], uint16(etherType))
+	return append(header, payload...)
+}
+
+// innerPacket is the tunneled packet: TCP from 10.0.0.1:1234 to 10.0.0.2:80
+func innerPacket() []byte {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], 1234)
+	binary.BigEndian.PutUint16(tcp[2:4], 80)
+	tcp[12] = 5 << 4
+	return craftIPv4Packet(layers.IPProtocolTCP, "10.0.0.1", "10.0.0.2", tcp)
+}
+
+// outerIPv4 encapsulates the payload in an IPv4 packet between the tunnel endpoints
+func outerIPv4(protocol layers.IPProtocol, payload []byte) []byte {
+	return craftEthernetFrame(layers.EthernetTypeIPv4, craftIPv4Packet(protocol, "192.168.0.1", "192.168.0.2", payload))
+}
+
+func TestTunnelDecapsulation(t *testing.T) {
+	vxlan := []byte{0x08, 0, 0, 0, 0, 0x12, 0x34, 0}
+	gtpu := []byte{0x30, gtpMessageTypeGPDU, 0, 0, 0xde, 0xad, 0xbe, 0xef}
+	binary.BigEndian.PutUint16(gtpu[2:4], uint16(len(innerPacket())))
+	greWithKey := []byte{0x20, 0, 0x08, 0, 0, 0, 0, 42}
+	greWithoutKey := []byte{0, 0, 0x08, 0}
+	// Two labels, the bottom of stack bit is set in the second one
+	mplsLabels := []byte{0x00, 0x01, 0x40, 0x40, 0x00, 0x02, 0x51, 0x40}
+
+	tests := []struct {
+		name        string
+		decapsulate []string
+		data        []byte
+		tunnelType  uint8
+		tunnelID    uint32
+		innerSrcIP  string
+	}{
+		{name: "not tunneled", decapsulate: []string{"gre", "vxlan", "gtpu", "mpls", "ipip"},
+			data: outerIPv4(layers.IPProtocolTCP, innerPacket()[20:]), tunnelType: flows.TunnelNone, innerSrcIP: "192.168.0.1"},
+		{name: "vxlan", decapsulate: []string{"vxlan"},
+			data:       outerIPv4(layers.IPProtocolUDP, craftUDPPacket(4789, append(vxlan, craftEthernetFrame(layers.EthernetTypeIPv4, innerPacket())...))),
+			tunnelType: flows.TunnelVXLAN, tunnelID: 0x1234, innerSrcIP: "10.0.0.1"},
+		{name: "vxlan disabled", decapsulate: []string{"gre"},
+			data:       outerIPv4(layers.IPProtocolUDP, craftUDPPacket(4789, append(vxlan, craftEthernetFrame(layers.EthernetTypeIPv4, innerPacket())...))),
+			tunnelType: flows.TunnelNone, innerSrcIP: "192.168.0.1"},
+		{name: "gtpu", decapsulate: []string{"gtpu"},
+			data:       outerIPv4(layers.IPProtocolUDP, craftUDPPacket(2152, append(gtpu, innerPacket()...))),
+			tunnelType: flows.TunnelGTPU, tunnelID: 0xdeadbeef, innerSrcIP: "10.0.0.1"},
+		{name: "gre with key", decapsulate: []string{"gre"},
+			data:       outerIPv4(layers.IPProtocolGRE, append(greWithKey, innerPacket()...)),
+			tunnelType: flows.TunnelGRE, tunnelID: 42, innerSrcIP: "10.0.0.1"},
+		{name: "gre without key", decapsulate: []string{"gre"},
+			data:       outerIPv4(layers.IPProtocolGRE, append(greWithoutKey, innerPacket()...)),
+			tunnelType: flows.TunnelGRE, innerSrcIP: "10.0.0.1"},
+		{name: "mpls", decapsulate: []string{"mpls"},
+			data:       craftEthernetFrame(layers.EthernetTypeMPLSUnicast, append(mplsLabels, innerPacket()...)),
+			tunnelType: flows.TunnelMPLS, tunnelID: 20, innerSrcIP: "10.0.0.1"},
+		{name: "ipip", decapsulate: []string{"ipip"},
+			data:       outerIPv4(layers.IPProtocolIPv4, innerPacket()),
+			tunnelType: flows.TunnelIPIP, innerSrcIP: "10.0.0.1"},
+		{name: "ipip disabled", decapsulate: []string{"gre"},
+			data:       outerIPv4(layers.IPProtocolIPv4, innerPacket()),
+			tunnelType: flows.TunnelNone, innerSrcIP: "192.168.0.1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decapsulation := newTunnelDecapsulation(test.decapsulate)
+			var eth layers.Ethernet
+			ipv4 := ipv4{decapsulate: decapsulation.ipip}
+			ipv6 := ipv6{decapsulate: decapsulation.ipip}
+			var tunnel tunnelLayers
+			var transport transportLayers
+			decodingLayers := []gopacket.DecodingLayer{&eth, &ipv4, &ipv6, &transport.tcp, &transport.udp}
+			decodingLayers = append(decodingLayers, tunnel.getDecodingLayers(decapsulation)...)
+			parser := gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, decodingLayers...)
+
+			var decoded []gopacket.LayerType
+			_ = parser.DecodeLayers(test.data, &decoded)
+			innermostIP := 0
+			for i, layerType := range decoded {
+				if layerType == layers.LayerTypeIPv4 || layerType == layers.LayerTypeIPv6 {
+					innermostIP = i
+				}
+			}
+			var packetInfo flows.PacketInformation
+			tunnel.setTunnelInformation(&packetInfo, decoded[:innermostIP])
+
+			if packetInfo.TunnelType != test.tunnelType || packetInfo.TunnelID != test.tunnelID {
+				t.Errorf("tunnel %s %d, want %s %d", flows.GetTunnelTypeString(packetInfo.TunnelType), packetInfo.TunnelID,
+					flows.GetTunnelTypeString(test.tunnelType), test.tunnelID)
+			}
+			// The layers of the innermost IP header are decoded last
+			if srcIP := ipv4.SrcIP.String(); srcIP != test.innerSrcIP {
+				t.Errorf("innermost source IP %s, want %s (decoded %v)", srcIP, test.innerSrcIP, decoded)
+			}
+		})
+	}
+}
+
+func TestTunnelFlowKey(t *testing.T) {
+	transport := transportLayers{udp: layers.UDP{SrcPort: 1234, DstPort: 53, Length: 8}}
+	getFlowKey := func(tunnelType uint8, tunnelID uint32, reversed bool) flows.FlowKeyType {
+		packetInfo := flows.PacketInformation{SrcIP: 1, DstIP: 2, TunnelType: tunnelType, TunnelID: tunnelID}
+		transport := transport
+		if reversed {
+			packetInfo.SrcIP, packetInfo.DstIP = packetInfo.DstIP, packetInfo.SrcIP
+			transport.udp.SrcPort, transport.udp.DstPort = transport.udp.DstPort, transport.udp.SrcPort
+		}
+		(&Parser{}).setTransportInformation(&packetInfo, layers.LayerTypeUDP, 8, &transport)
+		return packetInfo.FlowKey
+	}
+
+	notTunneled := getFlowKey(flows.TunnelNone, 0, false)
+	if notTunneled != GetFlowKey(1, 2, flows.UDP, 1234, 53) {
+		t.Error("the flow key of packets, which are not tunneled, is changed")
+	}
+	vni1 := getFlowKey(flows.TunnelVXLAN, 1, false)
+	keys := map[string]flows.FlowKeyType{
+		"not tunneled": notTunneled,
+		"VXLAN VNI 1":  vni1,
+		"VXLAN VNI 2":  getFlowKey(flows.TunnelVXLAN, 2, false),
+		"GTP-U TEID 1": getFlowKey(flows.TunnelGTPU, 1, false),
+		"GRE":          getFlowKey(flows.TunnelGRE, 0, false),
+	}
+	seen := make(map[flows.FlowKeyType]string)
+	for name, key := range keys {
+		if other, exists := seen[key]; exists {
+			t.Errorf("%s and %s have the same flow key", name, other)
+		}
+		seen[key] = name
+	}
+	if reversed := getFlowKey(flows.TunnelVXLAN, 1, true); reversed != vni1 {
+		t.Error("the flow key of a tunneled flow is not symmetric")
+	}
+}
@@ -0,0 +1,492 @@
+package pool
+
+// This file contains the flow filter, an expression which is evaluated when a flow is flushed.
+// Only flows matching the expression are passed to the metrics.
+//
+// Grammar:
+//   expression := term { ("or" | "||") term }
+//   term       := factor { ("and" | "&&") factor }
+//   factor     := ("not" | "!") factor | "(" expression ")" | keyword | field operator value | field "in" "(" value { "," value } ")"
+//                 | "state" ("==" | "!=") state | "state" "in" "(" state { "," state } ")"
+//   keyword    := tcp | udp | quic | sctp | icmp | icmpv6 | complete | closed | bidirectional | unidirectional
+//   field      := [client. | server.]ip | [client. | server.]port | [client. | server.]packets | [client. | server.]bytes | duration | interface | tunnel.id
+//   operator   := == | != | < | <= | > | >=
+// Addresses can be compared with IP addresses and subnets (e.g. client.ip in 10.0.0.0/8).
+// Numbers accept the suffixes k, m and g (e.g. bytes > 10k), durations accept Go durations or seconds (e.g. duration >= 1m30s).
+// ip and port match if the client or the server matches (for != both must match).
+// state is the Zeek connection state of TCP flows (e.g. state in (SF, S1)), other flows never match a state comparison.
+
+import (
+	"scalable-flow-analyzer/flows"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filterFlow is the flow the filter is evaluated on. tcpFlow is only set for TCP flows.
+type filterFlow struct {
+	flow    *flows.Flow
+	tcpFlow *flows.TCPFlow
+}
+
+// flowFilter is a node of the parsed filter expression
+type flowFilter interface {
+	matches(f filterFlow) bool
+}
+
+type andFilter struct{ left, right flowFilter }
+type orFilter struct{ left, right flowFilter }
+type notFilter struct{ filter flowFilter }
+type predicateFilter func(f filterFlow) bool
+
+func (a andFilter) matches(f filterFlow) bool { return a.left.matches(f) && a.right.matches(f) }
+func (o orFilter) matches(f filterFlow) bool  { return o.left.matches(f) || o.right.matches(f) }
+func (n notFilter) matches(f filterFlow) bool { return !n.filter.matches(f) }
+func (p predicateFilter) matches(f filterFlow) bool {
+	return p(f)
+}
+
+// numericFilter compares a numeric field of the flow with one or multiple values (in)
+type numericFilter struct {
+	field    func(f filterFlow) int64
+	operator string
+	values   []int64
+}
+
+func (n numericFilter) matches(f filterFlow) bool {
+	value := n.field(f)
+	switch n.operator {
+	case "==", "in":
+		for _, v := range n.values {
+			if value == v {
+				return true
+			}
+		}
+		return false
+	case "!=":
+		return value != n.values[0]
+	case "<":
+		return value < n.values[0]
+	case "<=":
+		return value <= n.values[0]
+	case ">":
+		return value > n.values[0]
+	default: // ">="
+		return value >= n.values[0]
+	}
+}
+
+// addressFilter checks whether an address of the flow is in one of the subnets
+type addressFilter struct {
+	field    func(f filterFlow) flows.IPAddress
+	negate   bool
+	networks []*net.IPNet
+}
+
+func (a addressFilter) matches(f filterFlow) bool {
+	address := a.field(f)
+	ip := net.IP(address[:])
+	for _, network := range a.networks {
+		if network.Contains(ip) {
+			return !a.negate
+		}
+	}
+	return a.negate
+}
+
+// stateFilter checks whether the connection state of a TCP flow is one of the states
+type stateFilter struct {
+	negate bool
+	states [flows.NumTCPConnectionStates]bool
+}
+
+func (s stateFilter) matches(f filterFlow) bool {
+	if f.tcpFlow == nil {
+		return false
+	}
+	return s.states[f.tcpFlow.ConnectionState()] != s.negate
+}
+
+// filterKeywords are the flow properties without value
+var filterKeywords = map[string]predicateFilter{
+	"tcp":    func(f filterFlow) bool { return f.flow.Protocol == flows.TCP },
+	"udp":    func(f filterFlow) bool { return f.flow.Protocol == flows.UDP },
+	"quic":   func(f filterFlow) bool { return f.flow.Protocol == flows.QUIC },
+	"sctp":   func(f filterFlow) bool { return f.flow.Protocol == flows.SCTP },
+	"icmp":   func(f filterFlow) bool { return f.flow.Protocol == flows.ICMP },
+	"icmpv6": func(f filterFlow) bool { return f.flow.Protocol == flows.ICMPv6 },
+	// TCP flows starting with a SYN (same condition as tcpDropIncomplete)
+	"complete": func(f filterFlow) bool {
+		return f.tcpFlow != nil && f.tcpFlow.TCPPacket[0].SYN && !f.tcpFlow.TCPPacket[0].ACK
+	},
+	// TCP flows terminated by FIN or RST
+	"closed": func(f filterFlow) bool {
+		return f.tcpFlow != nil && (f.tcpFlow.FirstFINIndex != -1 || f.tcpFlow.RSTIndex != -1)
+	},
+	"bidirectional": func(f filterFlow) bool {
+		return countPackets(f, true) > 0 && countPackets(f, false) > 0
+	},
+	"unidirectional": func(f filterFlow) bool {
+		return countPackets(f, true) == 0 || countPackets(f, false) == 0
+	},
+}
+
+// numericFields are the numeric flow properties. Fields with client or server variant are listed in directionalFields.
+var numericFields = map[string]func(f filterFlow) int64{
+	"client.port":    func(f filterFlow) int64 { return int64(f.flow.ClientPort) },
+	"server.port":    func(f filterFlow) int64 { return int64(f.flow.ServerPort) },
+	"packets":        func(f filterFlow) int64 { return int64(len(f.flow.Packets)) },
+	"client.packets": func(f filterFlow) int64 { return countPackets(f, true) },
+	"server.packets": func(f filterFlow) int64 { return countPackets(f, false) },
+	"bytes":          func(f filterFlow) int64 { return countBytes(f, true) + countBytes(f, false) },
+	"client.bytes":   func(f filterFlow) int64 { return countBytes(f, true) },
+	"server.bytes":   func(f filterFlow) int64 { return countBytes(f, false) },
+	"duration": func(f filterFlow) int64 {
+		return f.flow.Packets[len(f.flow.Packets)-1].Timestamp - f.flow.Packets[0].Timestamp
+	},
+	"interface": func(f filterFlow) int64 { return int64(f.flow.Interface) },
+	"tunnel.id": func(f filterFlow) int64 { return int64(f.flow.TunnelID) },
+}
+
+// addressFields are the address flow properties
+var addressFields = map[string]func(f filterFlow) flows.IPAddress{
+	"client.ip": func(f filterFlow) flows.IPAddress { return f.flow.ClientIPAddress },
+	"server.ip": func(f filterFlow) flows.IPAddress { return f.flow.ServerIPAddress },
+}
+
+// directionalFields match if the client or the server field matches
+var directionalFields = map[string]bool{"ip": true, "port": true}
+
+// countPackets returns the number of packets sent by the client (fromClient) or the server
+func countPackets(f filterFlow, fromClient bool) (numPackets int64) {
+	for _, packet := range f.flow.Packets {
+		if packet.FromClient == fromClient {
+			numPackets++
+		}
+	}
+	return numPackets
+}
+
+// countBytes returns the number of payload bytes sent by the client (fromClient) or the server
+func countBytes(f filterFlow, fromClient bool) (numBytes int64) {
+	for _, packet := range f.flow.Packets {
+		if packet.FromClient == fromClient {
+			numBytes += int64(packet.LengthPayload)
+		}
+	}
+	return numBytes
+}
+
+// filterParser is a recursive descent parser for flow filter expressions
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseFlowFilter parses the flow filter expression. Returns nil for an empty expression (all flows match).
+func parseFlowFilter(expression string) (flowFilter, error) {
+	tokens, err := tokenizeFlowFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	p := &filterParser{tokens: tokens}
+	filter, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return filter, nil
+}
+
+// filterOperators are the operators consisting of two characters
+var filterOperators = map[string]bool{"==": true, "!=": true, "<=": true, ">=": true, "&&": true, "||": true}
+
+// tokenizeFlowFilter splits the expression into parentheses, commas, operators and words (keywords, fields and values)
+func tokenizeFlowFilter(expression string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			if i+2 <= len(expression) && filterOperators[expression[i:i+2]] {
+				tokens = append(tokens, expression[i:i+2])
+				i += 2
+				continue
+			}
+			switch c {
+			case '=':
+				// "=" and "==" are equivalent
+				tokens = append(tokens, "==")
+			case '&', '|':
+				return nil, fmt.Errorf("invalid operator %q", c)
+			default:
+				tokens = append(tokens, string(c))
+			}
+			i++
+		default:
+			start := i
+			for i < len(expression) && !strings.ContainsRune(" \t\n(),=!<>&|", rune(expression[i])) {
+				i++
+			}
+			tokens = append(tokens, strings.ToLower(expression[start:i]))
+		}
+	}
+	return tokens, nil
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", errors.New("unexpected end of filter")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) parseExpression() (flowFilter, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" || p.peek() == "||" {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = orFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseTerm() (flowFilter, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" || p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = andFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseFactor() (flowFilter, error) {
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case "not", "!":
+		filter, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{filter}, nil
+	case "(":
+		filter, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if token, err = p.next(); err != nil || token != ")" {
+			return nil, errors.New("missing )")
+		}
+		return filter, nil
+	}
+	if keyword, exists := filterKeywords[token]; exists {
+		return keyword, nil
+	}
+	return p.parseComparison(token)
+}
+
+// parseComparison parses the operator and the value(s) of the field
+func (p *filterParser) parseComparison(field string) (flowFilter, error) {
+	_, isNumeric := numericFields[field]
+	_, isAddress := addressFields[field]
+	if !isNumeric && !isAddress && !directionalFields[field] && field != "state" {
+		return nil, fmt.Errorf("unknown field or keyword %q", field)
+	}
+	operator, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	switch operator {
+	case "in":
+		values, err = p.parseList()
+		if err != nil {
+			return nil, err
+		}
+	case "==", "!=", "<", "<=", ">", ">=":
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		values = []string{value}
+	default:
+		return nil, fmt.Errorf("invalid operator %q for %s", operator, field)
+	}
+
+	if directionalFields[field] {
+		client, err := p.newComparison("client."+field, operator, values)
+		if err != nil {
+			return nil, err
+		}
+		server, _ := p.newComparison("server."+field, operator, values)
+		if operator == "!=" {
+			return andFilter{client, server}, nil
+		}
+		return orFilter{client, server}, nil
+	}
+	return p.newComparison(field, operator, values)
+}
+
+// parseList parses a list of values in parentheses. A single value without parentheses is accepted as well.
+func (p *filterParser) parseList() ([]string, error) {
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if token != "(" {
+		return []string{token}, nil
+	}
+	var values []string
+	for {
+		value, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if token, err = p.next(); err != nil {
+			return nil, err
+		}
+		if token == ")" {
+			return values, nil
+		}
+		if token != "," {
+			return nil, fmt.Errorf("expected , or ) instead of %q", token)
+		}
+	}
+}
+
+// newComparison creates the filter comparing the field with the values
+func (p *filterParser) newComparison(field, operator string, values []string) (flowFilter, error) {
+	if addressField, isAddress := addressFields[field]; isAddress {
+		if operator != "==" && operator != "!=" && operator != "in" {
+			return nil, fmt.Errorf("invalid operator %q for %s", operator, field)
+		}
+		filter := addressFilter{field: addressField, negate: operator == "!="}
+		for _, value := range values {
+			network, err := parseNetwork(value)
+			if err != nil {
+				return nil, err
+			}
+			filter.networks = append(filter.networks, network)
+		}
+		return filter, nil
+	}
+
+	if field == "state" {
+		if operator != "==" && operator != "!=" && operator != "in" {
+			return nil, fmt.Errorf("invalid operator %q for %s", operator, field)
+		}
+		filter := stateFilter{negate: operator == "!="}
+		for _, value := range values {
+			state, ok := flows.ParseTCPConnectionState(value)
+			if !ok {
+				return nil, fmt.Errorf("invalid value %q for %s", value, field)
+			}
+			filter.states[state] = true
+		}
+		return filter, nil
+	}
+
+	filter := numericFilter{field: numericFields[field], operator: operator}
+	for _, value := range values {
+		var number int64
+		var err error
+		if field == "duration" {
+			number, err = parseFilterDuration(value)
+		} else {
+			number, err = parseFilterNumber(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s", value, field)
+		}
+		filter.values = append(filter.values, number)
+	}
+	return filter, nil
+}
+
+// parseNetwork parses a subnet (CIDR notation) or a single IP address
+func parseNetwork(value string) (*net.IPNet, error) {
+	if strings.Contains(value, "/") {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet %q", value)
+		}
+		return network, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address %q", value)
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return &net.IPNet{IP: ipv4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// parseFilterNumber parses an integer with an optional suffix k (10^3), m (10^6) or g (10^9)
+func parseFilterNumber(value string) (int64, error) {
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "k"):
+		multiplier = 1e3
+	case strings.HasSuffix(value, "m"):
+		multiplier = 1e6
+	case strings.HasSuffix(value, "g"):
+		multiplier = 1e9
+	}
+	if multiplier != 1 {
+		value = value[:len(value)-1]
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(number * multiplier)), nil
+}
+
+// parseFilterDuration parses a Go duration (e.g. 1m30s) or a number of seconds. Returns nanoseconds.
+func parseFilterDuration(value string) (int64, error) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return int64(seconds * float64(time.Second)), nil
+	}
+	duration, err := time.ParseDuration(value)
+	return int64(duration), err
+}
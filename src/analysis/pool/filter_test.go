@@ -0,0 +1,173 @@
+package pool
+
+import (
+	"scalable-flow-analyzer/flows"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestTCPFlow creates a TCP flow between the addresses with packets (sent by the client, TCP flags, payload length)
+func newTestTCPFlow(client, server string, clientPort, serverPort uint16, packets []flows.Packet, tcpPackets []flows.TCPPacket) *flows.TCPFlow {
+	return &flows.TCPFlow{
+		Flow: flows.Flow{
+			Protocol:        flows.TCP,
+			ClientIPAddress: flows.NewIPAddress(net.ParseIP(client).To4()),
+			ServerIPAddress: flows.NewIPAddress(net.ParseIP(server).To4()),
+			ClientPort:      clientPort,
+			ServerPort:      serverPort,
+			Packets:         packets,
+		},
+		TCPPacket:     tcpPackets,
+		FirstFINIndex: -1,
+		RSTIndex:      -1,
+	}
+}
+
+// newTestFilterFlows returns the flows the filters are evaluated on:
+//
+//	sf:  TCP 10.0.0.1:50000 -> 192.168.1.1:443 on interface 1, handshake, 100/2000 bytes, closed by both sides after 1.5s
+//	s0:  TCP 10.0.0.2:50001 -> 192.168.1.2:80, unanswered SYN
+//	udp: UDP 10.1.0.1:5353 -> 8.8.8.8:53 in tunnel 7, 50/150 bytes
+func newTestFilterFlows() map[string]filterFlow {
+	ms := int64(time.Millisecond)
+	sf := newTestTCPFlow("10.0.0.1", "192.168.1.1", 50000, 443,
+		[]flows.Packet{{Timestamp: 0, FromClient: true}, {Timestamp: 1 * ms}, {Timestamp: 2 * ms, FromClient: true, LengthPayload: 100},
+			{Timestamp: 3 * ms, LengthPayload: 2000}, {Timestamp: 1500 * ms, FromClient: true}, {Timestamp: 1501 * ms}},
+		[]flows.TCPPacket{{SYN: true}, {SYN: true, ACK: true}, {ACK: true}, {ACK: true}, {FIN: true, ACK: true}, {FIN: true, ACK: true}})
+	sf.FirstFINIndex = 4
+	sf.Interface = 1
+	s0 := newTestTCPFlow("10.0.0.2", "192.168.1.2", 50001, 80,
+		[]flows.Packet{{Timestamp: 0, FromClient: true}}, []flows.TCPPacket{{SYN: true}})
+	udp := &flows.UDPFlow{Flow: flows.Flow{
+		Protocol:        flows.UDP,
+		ClientIPAddress: flows.NewIPAddress(net.ParseIP("10.1.0.1").To4()),
+		ServerIPAddress: flows.NewIPAddress(net.ParseIP("8.8.8.8").To4()),
+		ClientPort:      5353,
+		ServerPort:      53,
+		TunnelType:      flows.TunnelVXLAN,
+		TunnelID:        7,
+		Packets:         []flows.Packet{{Timestamp: 0, FromClient: true, LengthPayload: 50}, {Timestamp: 10 * ms, LengthPayload: 150}},
+	}}
+	return map[string]filterFlow{
+		"sf":  {flow: &sf.Flow, tcpFlow: sf},
+		"s0":  {flow: &s0.Flow, tcpFlow: s0},
+		"udp": {flow: &udp.Flow},
+	}
+}
+
+func TestFlowFilter(t *testing.T) {
+	tests := []struct {
+		expression string
+		matches    string // Names of the matching flows, sorted
+	}{
+		// Keywords
+		{"", "s0,sf,udp"},
+		{"tcp", "s0,sf"},
+		{"TCP", "s0,sf"},
+		{"udp", "udp"},
+		{"complete", "s0,sf"},
+		{"closed", "sf"},
+		{"bidirectional", "sf,udp"},
+		{"unidirectional", "s0"},
+		// Precedence and negation
+		{"udp or tcp and port == 443", "sf,udp"},
+		{"(udp or tcp) and port == 443", "sf"},
+		{"udp || tcp && port == 443", "sf,udp"},
+		{"tcp and not closed", "s0"},
+		{"!tcp", "udp"},
+		{"not not udp", "udp"},
+		{"not (tcp or udp)", ""},
+		// Addresses and subnets, ip matches the client or the server
+		{"ip == 10.0.0.1", "sf"},
+		{"ip = 192.168.1.1", "sf"},
+		{"client.ip == 192.168.1.1", ""},
+		{"server.ip == 192.168.0.0/16", "s0,sf"},
+		{"ip in 10.0.0.0/24", "s0,sf"},
+		{"ip in (10.1.0.0/16, 192.168.1.2)", "s0,udp"},
+		{"ip != 10.0.0.1", "s0,udp"},
+		{"ip != 10.0.0.0/8", ""},
+		// Ports, port matches the client or the server, for != both must match
+		{"port == 443", "sf"},
+		{"port != 443", "s0,udp"},
+		{"port != 53", "s0,sf"},
+		{"port in (53, 80)", "s0,udp"},
+		{"client.port >= 50000", "s0,sf"},
+		{"server.port < 100", "s0,udp"},
+		// Numbers with suffixes
+		{"packets == 6", "sf"},
+		{"client.packets <= 1", "s0,udp"},
+		{"bytes > 1k", "sf"},
+		{"bytes >= 2.1k", "sf"},
+		{"bytes > 2.1k", ""},
+		{"server.bytes < 200", "s0,udp"},
+		{"bytes < 0.001m", "s0,udp"},
+		{"bytes < 1g", "s0,sf,udp"},
+		// Durations as Go durations or seconds
+		{"duration >= 1s", "sf"},
+		{"duration > 1.4", "sf"},
+		{"duration < 1m30s", "s0,sf,udp"},
+		{"duration == 0", "s0"},
+		// Connection state, only TCP flows match
+		{"state == SF", "sf"},
+		{"state == sf", "sf"},
+		{"state in (S0, S1)", "s0"},
+		{"state != SF", "s0"},
+		{"not state == SF", "s0,udp"},
+		// Other fields
+		{"interface == 1", "sf"},
+		{"tunnel.id == 7", "udp"},
+	}
+
+	testFlows := newTestFilterFlows()
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			filter, err := parseFlowFilter(test.expression)
+			if err != nil {
+				t.Fatalf("parseFlowFilter(%q) failed: %v", test.expression, err)
+			}
+			var matches []string
+			for name, flow := range testFlows {
+				if filter == nil || filter.matches(flow) {
+					matches = append(matches, name)
+				}
+			}
+			sort.Strings(matches)
+			if got := strings.Join(matches, ","); got != test.matches {
+				t.Errorf("%q matches %q, want %q", test.expression, got, test.matches)
+			}
+		})
+	}
+}
+
+func TestFlowFilterErrors(t *testing.T) {
+	expressions := []string{
+		"tcp and",
+		"(tcp",
+		"tcp)",
+		"tcp udp",
+		"foo",
+		"client.foo == 1",
+		"port",
+		"port ~ 1",
+		"port in (1, 2",
+		"port in (1 2)",
+		"tcp & udp",
+		"ip < 10.0.0.1",
+		"ip == 10.0.0.300",
+		"ip == 10.0.0.0/33",
+		"bytes > 10x",
+		"duration > 1y",
+		"state == XY",
+		"state > SF",
+	}
+	for _, expression := range expressions {
+		t.Run(expression, func(t *testing.T) {
+			if _, err := parseFlowFilter(expression); err == nil {
+				t.Errorf("parseFlowFilter(%q) succeeded", expression)
+			}
+		})
+	}
+}
@@ -11,21 +11,33 @@ import (
 
 // Pool is a collection of Flows previously seen
 type pool struct {
-	addTCPPacketCache   packetInformationCache
-	addTCPPacketChannel chan [packetInformationCacheSize]flows.PacketInformation
-	addUDPPacketCache   packetInformationCache
-	addUDPPacketChannel chan [packetInformationCacheSize]flows.PacketInformation
-	tcpFlows            map[flows.FlowKeyType]*flows.TCPFlow // each flowthread has its own map to avoid concurrency
-	udpFlows            map[flows.FlowKeyType]*flows.UDPFlow // each flowthread has its own map to avoid concurrency
-	metrics             []metrics.Metric
-	currentTCPTime      int64
-	currentUDPTime      int64
-	wgAddPacket         sync.WaitGroup
-	tcpFlowsLock        sync.Mutex // Lock synchronizes with flushing
-	udpFlowsLock        sync.Mutex // Lock synchronizes with flushing
-	tcpFilter           [65536]bool
-	udpFilter           [65536]bool
-	tcpDropIncomplete   bool
+	addTCPPacketCache    packetInformationCache
+	addTCPPacketChannel  chan [packetInformationCacheSize]flows.PacketInformation
+	addUDPPacketCache    packetInformationCache
+	addUDPPacketChannel  chan [packetInformationCacheSize]flows.PacketInformation
+	addSCTPPacketCache   packetInformationCache
+	addSCTPPacketChannel chan [packetInformationCacheSize]flows.PacketInformation
+	addICMPPacketCache   packetInformationCache
+	addICMPPacketChannel chan [packetInformationCacheSize]flows.PacketInformation
+	tcpFlows             map[flows.FlowKeyType]*flows.TCPFlow  // each flowthread has its own map to avoid concurrency
+	udpFlows             map[flows.FlowKeyType]*flows.UDPFlow  // each flowthread has its own map to avoid concurrency
+	sctpFlows            map[flows.FlowKeyType]*flows.SCTPFlow // each flowthread has its own map to avoid concurrency
+	icmpFlows            map[flows.FlowKeyType]*flows.ICMPFlow // each flowthread has its own map to avoid concurrency
+	metrics              []metrics.Metric
+	currentTCPTime       int64
+	currentUDPTime       int64
+	currentSCTPTime      int64
+	currentICMPTime      int64
+	wgAddPacket          sync.WaitGroup
+	wgPendingBatches     sync.WaitGroup // Batches sent to the channels, which are not yet added to the flows
+	tcpFlowsLock         sync.Mutex     // Lock synchronizes with flushing
+	udpFlowsLock         sync.Mutex     // Lock synchronizes with flushing
+	sctpFlowsLock        sync.Mutex     // Lock synchronizes with flushing
+	icmpFlowsLock        sync.Mutex     // Lock synchronizes with flushing
+	tcpFilter            [65536]bool
+	udpFilter            [65536]bool
+	tcpDropIncomplete    bool
+	flowFilter           flowFilter // nil if all flows are passed to the metrics
 }
 
 type packetInformationCache struct {
@@ -34,8 +46,8 @@ type packetInformationCache struct {
 }
 
 // NewPool creates an empty pool of flows
-func newPool(tcpFilter, udpFilter *[65536]bool, tcpDropIncomplete bool) *pool {
-	p := pool{tcpFilter: *tcpFilter, udpFilter: *udpFilter, tcpDropIncomplete: tcpDropIncomplete}
+func newPool(tcpFilter, udpFilter *[65536]bool, tcpDropIncomplete bool, flowFilter flowFilter) *pool {
+	p := pool{tcpFilter: *tcpFilter, udpFilter: *udpFilter, tcpDropIncomplete: tcpDropIncomplete, flowFilter: flowFilter}
 
 	// Start goroutines to add packets
 	p.wgAddPacket.Add(1)
@@ -48,6 +60,16 @@ func newPool(tcpFilter, udpFilter *[65536]bool, tcpDropIncomplete bool) *pool {
 	p.addUDPPacketChannel = make(chan [packetInformationCacheSize]flows.PacketInformation, addPacketChannelSize)
 	go p.addUDPPackets()
 
+	p.wgAddPacket.Add(1)
+	p.sctpFlows = make(map[flows.FlowKeyType]*flows.SCTPFlow)
+	p.addSCTPPacketChannel = make(chan [packetInformationCacheSize]flows.PacketInformation, addPacketChannelSize)
+	go p.addSCTPPackets()
+
+	p.wgAddPacket.Add(1)
+	p.icmpFlows = make(map[flows.FlowKeyType]*flows.ICMPFlow)
+	p.addICMPPacketChannel = make(chan [packetInformationCacheSize]flows.PacketInformation, addPacketChannelSize)
+	go p.addICMPPackets()
+
 	return &p
 }
 
@@ -56,20 +78,68 @@ func (p *pool) close() {
 	// Write remaining packets from channels to flows
 	tmp := [packetInformationCacheSize]flows.PacketInformation{}
 	copy(tmp[:p.addTCPPacketCache.pos], p.addTCPPacketCache.buf[:p.addTCPPacketCache.pos])
+	p.wgPendingBatches.Add(1)
 	p.addTCPPacketChannel <- tmp
 	close(p.addTCPPacketChannel)
 	tmp = [packetInformationCacheSize]flows.PacketInformation{}
 	copy(tmp[:p.addUDPPacketCache.pos], p.addUDPPacketCache.buf[:p.addUDPPacketCache.pos])
+	p.wgPendingBatches.Add(1)
 	p.addUDPPacketChannel <- tmp
 	close(p.addUDPPacketChannel)
+	tmp = [packetInformationCacheSize]flows.PacketInformation{}
+	copy(tmp[:p.addSCTPPacketCache.pos], p.addSCTPPacketCache.buf[:p.addSCTPPacketCache.pos])
+	p.wgPendingBatches.Add(1)
+	p.addSCTPPacketChannel <- tmp
+	close(p.addSCTPPacketChannel)
+	tmp = [packetInformationCacheSize]flows.PacketInformation{}
+	copy(tmp[:p.addICMPPacketCache.pos], p.addICMPPacketCache.buf[:p.addICMPPacketCache.pos])
+	p.wgPendingBatches.Add(1)
+	p.addICMPPacketChannel <- tmp
+	close(p.addICMPPacketChannel)
 
 	p.wgAddPacket.Wait()
 }
 
+// drain adds the cached packets to the flows and blocks until all packets sent to the channels are added.
+// Must not be called concurrently with adding packets.
+func (p *pool) drain() {
+	if p.addTCPPacketCache.pos > 0 {
+		tmp := [packetInformationCacheSize]flows.PacketInformation{}
+		copy(tmp[:p.addTCPPacketCache.pos], p.addTCPPacketCache.buf[:p.addTCPPacketCache.pos])
+		p.wgPendingBatches.Add(1)
+		p.addTCPPacketChannel <- tmp
+		p.addTCPPacketCache.pos = 0
+	}
+	if p.addUDPPacketCache.pos > 0 {
+		tmp := [packetInformationCacheSize]flows.PacketInformation{}
+		copy(tmp[:p.addUDPPacketCache.pos], p.addUDPPacketCache.buf[:p.addUDPPacketCache.pos])
+		p.wgPendingBatches.Add(1)
+		p.addUDPPacketChannel <- tmp
+		p.addUDPPacketCache.pos = 0
+	}
+	if p.addSCTPPacketCache.pos > 0 {
+		tmp := [packetInformationCacheSize]flows.PacketInformation{}
+		copy(tmp[:p.addSCTPPacketCache.pos], p.addSCTPPacketCache.buf[:p.addSCTPPacketCache.pos])
+		p.wgPendingBatches.Add(1)
+		p.addSCTPPacketChannel <- tmp
+		p.addSCTPPacketCache.pos = 0
+	}
+	if p.addICMPPacketCache.pos > 0 {
+		tmp := [packetInformationCacheSize]flows.PacketInformation{}
+		copy(tmp[:p.addICMPPacketCache.pos], p.addICMPPacketCache.buf[:p.addICMPPacketCache.pos])
+		p.wgPendingBatches.Add(1)
+		p.addICMPPacketChannel <- tmp
+		p.addICMPPacketCache.pos = 0
+	}
+
+	p.wgPendingBatches.Wait()
+}
+
 func (p *pool) addTCPPacket(packet *flows.PacketInformation) {
 	p.addTCPPacketCache.buf[p.addTCPPacketCache.pos] = *packet
 	p.addTCPPacketCache.pos++
 	if p.addTCPPacketCache.pos == packetInformationCacheSize {
+		p.wgPendingBatches.Add(1)
 		p.addTCPPacketChannel <- p.addTCPPacketCache.buf
 		p.addTCPPacketCache.pos = 0
 	}
@@ -110,6 +180,7 @@ func (p *pool) addTCPPackets() {
 			}
 		}
 		p.tcpFlowsLock.Unlock()
+		p.wgPendingBatches.Done()
 	}
 	p.wgAddPacket.Done()
 }
@@ -118,6 +189,7 @@ func (p *pool) addUDPPacket(packet *flows.PacketInformation) {
 	p.addUDPPacketCache.buf[p.addUDPPacketCache.pos] = *packet
 	p.addUDPPacketCache.pos++
 	if p.addUDPPacketCache.pos == packetInformationCacheSize {
+		p.wgPendingBatches.Add(1)
 		p.addUDPPacketChannel <- p.addUDPPacketCache.buf
 		p.addUDPPacketCache.pos = 0
 	}
@@ -150,6 +222,93 @@ func (p *pool) addUDPPackets() {
 			}
 		}
 		p.udpFlowsLock.Unlock()
+		p.wgPendingBatches.Done()
+	}
+	p.wgAddPacket.Done()
+}
+
+func (p *pool) addSCTPPacket(packet *flows.PacketInformation) {
+	p.addSCTPPacketCache.buf[p.addSCTPPacketCache.pos] = *packet
+	p.addSCTPPacketCache.pos++
+	if p.addSCTPPacketCache.pos == packetInformationCacheSize {
+		p.wgPendingBatches.Add(1)
+		p.addSCTPPacketChannel <- p.addSCTPPacketCache.buf
+		p.addSCTPPacketCache.pos = 0
+	}
+}
+
+func (p *pool) addSCTPPackets() {
+	for sctpPackets := range p.addSCTPPacketChannel {
+		p.sctpFlowsLock.Lock()
+		for _, sctpPacket := range &sctpPackets {
+			if sctpPacket.PacketIdx == 0 {
+				continue
+			}
+			p.currentSCTPTime = sctpPacket.Timestamp
+			flow, flowExists := p.sctpFlows[sctpPacket.FlowKey]
+			// Check if association is timedout or a new association is establishing
+			if flowExists {
+				// Check if association timed out. Exception: ABORT is set, then it belongs to current flow
+				if !sctpPacket.SCTPABORT && p.flushSCTPFlow(flow, false) {
+					flowExists = false
+				}
+
+				// If new association and old association was terminated: Force flush
+				if flowExists && sctpPacket.SCTPINIT && (flow.FirstShutdownIndex != -1 || flow.AbortIndex != -1) {
+					p.flushSCTPFlow(flow, true)
+					flowExists = false
+				}
+			}
+			// Create new flow
+			if !flowExists {
+				flow = flows.NewSCTPFlow(sctpPacket)
+				p.sctpFlows[flow.FlowKey] = flow
+			} else {
+				// Add packet to existing flow
+				flow.AddPacket(sctpPacket)
+			}
+		}
+		p.sctpFlowsLock.Unlock()
+		p.wgPendingBatches.Done()
+	}
+	p.wgAddPacket.Done()
+}
+
+func (p *pool) addICMPPacket(packet *flows.PacketInformation) {
+	p.addICMPPacketCache.buf[p.addICMPPacketCache.pos] = *packet
+	p.addICMPPacketCache.pos++
+	if p.addICMPPacketCache.pos == packetInformationCacheSize {
+		p.wgPendingBatches.Add(1)
+		p.addICMPPacketChannel <- p.addICMPPacketCache.buf
+		p.addICMPPacketCache.pos = 0
+	}
+}
+
+func (p *pool) addICMPPackets() {
+	for icmpPackets := range p.addICMPPacketChannel {
+		p.icmpFlowsLock.Lock()
+		for _, icmpPacket := range &icmpPackets {
+			if icmpPacket.PacketIdx == 0 {
+				continue
+			}
+			p.currentICMPTime = icmpPacket.Timestamp
+			flow, flowExists := p.icmpFlows[icmpPacket.FlowKey]
+			// Check if flow is timedout
+			if flowExists && p.flushICMPFlow(flow, false) {
+				flowExists = false
+			}
+
+			// Create new flow
+			if !flowExists {
+				flow = flows.NewICMPFlow(icmpPacket)
+				p.icmpFlows[flow.FlowKey] = flow
+			} else {
+				// Add packet to existing flow
+				flow.AddPacket(icmpPacket)
+			}
+		}
+		p.icmpFlowsLock.Unlock()
+		p.wgPendingBatches.Done()
 	}
 	p.wgAddPacket.Done()
 }
@@ -163,6 +322,10 @@ func (p *pool) flushTCPFlow(flow *flows.TCPFlow, force bool) bool {
 		if !p.tcpFilter[flow.ServerPort] || (p.tcpDropIncomplete && (!flow.TCPPacket[0].SYN || flow.TCPPacket[0].ACK)) {
 			return true
 		}
+		// Ignore flows not matching the flow filter
+		if p.flowFilter != nil && !p.flowFilter.matches(filterFlow{flow: &flow.Flow, tcpFlow: flow}) {
+			return true
+		}
 
 		for _, metric := range p.metrics {
 			metric.OnTCPFlush(flow)
@@ -181,6 +344,10 @@ func (p *pool) flushUDPFlow(flow *flows.UDPFlow, force bool) bool {
 		if !p.udpFilter[flow.ServerPort] {
 			return true
 		}
+		// Ignore flows not matching the flow filter
+		if p.flowFilter != nil && !p.flowFilter.matches(filterFlow{flow: &flow.Flow}) {
+			return true
+		}
 
 		for _, metric := range p.metrics {
 			metric.OnUDPFlush(flow)
@@ -191,8 +358,44 @@ func (p *pool) flushUDPFlow(flow *flows.UDPFlow, force bool) bool {
 	return false
 }
 
+// flushSCTPFlow flushes an SCTP association if has timed out, or force=true. Returns whether association has been flushed.
+func (p *pool) flushSCTPFlow(flow *flows.SCTPFlow, force bool) bool {
+	// Needs Flush
+	if force || p.currentSCTPTime > flow.Flow.Timeout {
+		// Ignore flows not matching the flow filter
+		if p.flowFilter != nil && !p.flowFilter.matches(filterFlow{flow: &flow.Flow}) {
+			return true
+		}
+
+		for _, metric := range p.metrics {
+			metric.OnSCTPFlush(flow)
+		}
+
+		return true
+	}
+	return false
+}
+
+// flushICMPFlow flushes an ICMP flow if has timed out, or force=true. Returns whether flow has been flushed.
+func (p *pool) flushICMPFlow(flow *flows.ICMPFlow, force bool) bool {
+	// Needs Flush
+	if force || p.currentICMPTime > flow.Flow.Timeout {
+		// Ignore flows not matching the flow filter
+		if p.flowFilter != nil && !p.flowFilter.matches(filterFlow{flow: &flow.Flow}) {
+			return true
+		}
+
+		for _, metric := range p.metrics {
+			metric.OnICMPFlush(flow)
+		}
+
+		return true
+	}
+	return false
+}
+
 // Flush will flush all closed connections
-func (p *pool) flush(force bool, wgFlush *sync.WaitGroup, tcpFlushed, tcpCount, udpFlushed, udpCount *int64, counterLock *sync.Mutex) {
+func (p *pool) flush(force bool, wgFlush *sync.WaitGroup, tcpFlushed, tcpCount, udpFlushed, udpCount, sctpFlushed, sctpCount, icmpFlushed, icmpCount *int64, counterLock *sync.Mutex) {
 	// Start concurrent threads which can check if Flows needs flushing concurrently
 	wgFlush.Add(1)
 	go func(force bool, wgFlush *sync.WaitGroup) {
@@ -233,6 +436,46 @@ func (p *pool) flush(force bool, wgFlush *sync.WaitGroup, tcpFlushed, tcpCount,
 		counterLock.Unlock()
 		wgFlush.Done()
 	}(force, wgFlush)
+
+	wgFlush.Add(1)
+	go func(force bool, wgFlush *sync.WaitGroup) {
+		p.sctpFlowsLock.Lock()
+		counterLock.Lock()
+		*sctpCount += int64(len(p.sctpFlows))
+		counterLock.Unlock()
+		var flushed int64
+		for _, flow := range p.sctpFlows {
+			if p.flushSCTPFlow(flow, force) {
+				delete(p.sctpFlows, flow.FlowKey)
+				flushed++
+			}
+		}
+		p.sctpFlowsLock.Unlock()
+		counterLock.Lock()
+		*sctpFlushed += flushed
+		counterLock.Unlock()
+		wgFlush.Done()
+	}(force, wgFlush)
+
+	wgFlush.Add(1)
+	go func(force bool, wgFlush *sync.WaitGroup) {
+		p.icmpFlowsLock.Lock()
+		counterLock.Lock()
+		*icmpCount += int64(len(p.icmpFlows))
+		counterLock.Unlock()
+		var flushed int64
+		for _, flow := range p.icmpFlows {
+			if p.flushICMPFlow(flow, force) {
+				delete(p.icmpFlows, flow.FlowKey)
+				flushed++
+			}
+		}
+		p.icmpFlowsLock.Unlock()
+		counterLock.Lock()
+		*icmpFlushed += flushed
+		counterLock.Unlock()
+		wgFlush.Done()
+	}(force, wgFlush)
 }
 
 // registerMetric registers a Metric which shall be called on flush
@@ -241,7 +484,7 @@ func (p *pool) registerMetric(metric metrics.Metric) {
 }
 
 // printStatistics print so>me statistics about the pool
-func (p *pool) printStatistics(numTCPFlows, numTCPPackets, numUDPFlows, numUDPPackets *int64, counterLock *sync.Mutex) {
+func (p *pool) printStatistics(numTCPFlows, numTCPPackets, numUDPFlows, numUDPPackets, numSCTPFlows, numSCTPPackets, numICMPFlows, numICMPPackets *int64, counterLock *sync.Mutex) {
 	var numFlows int64
 	var numPackets int64
 	p.tcpFlowsLock.Lock()
@@ -267,4 +510,30 @@ func (p *pool) printStatistics(numTCPFlows, numTCPPackets, numUDPFlows, numUDPPa
 	*numUDPFlows += numFlows
 	*numUDPPackets += numPackets
 	counterLock.Unlock()
+
+	numFlows = 0
+	numPackets = 0
+	p.sctpFlowsLock.Lock()
+	numFlows += int64(len(p.sctpFlows))
+	for _, flow := range p.sctpFlows {
+		numPackets += int64(len(flow.Packets))
+	}
+	p.sctpFlowsLock.Unlock()
+	counterLock.Lock()
+	*numSCTPFlows += numFlows
+	*numSCTPPackets += numPackets
+	counterLock.Unlock()
+
+	numFlows = 0
+	numPackets = 0
+	p.icmpFlowsLock.Lock()
+	numFlows += int64(len(p.icmpFlows))
+	for _, flow := range p.icmpFlows {
+		numPackets += int64(len(flow.Packets))
+	}
+	p.icmpFlowsLock.Unlock()
+	counterLock.Lock()
+	*numICMPFlows += numFlows
+	*numICMPPackets += numPackets
+	counterLock.Unlock()
 }
@@ -6,27 +6,34 @@ import (
 	"scalable-flow-analyzer/flows"
 	"scalable-flow-analyzer/metrics"
 	"fmt"
+	"log"
 	"sync"
 
 	"github.com/dustin/go-humanize"
 )
 
-// numFlowThreads defines the number of Threads (x2 (TCP & UDP)) which are responsible to add packets
+// numFlowThreads defines the number of Threads (x4 (TCP, UDP, SCTP & ICMP)) which are responsible to add packets
 const numFlowThreads = 64
 
 // addPacketChannelSize defines the size of the channel
 const addPacketChannelSize = 400
 
 // packetInformationCacheSize is the batching size of the packets sent to the addPacket Channels
-const packetInformationCacheSize = 512
+// Note: Channel elements are limited to 64kB, so the batch (packetInformationCacheSize * size of flows.PacketInformation) must not exceed it
+const packetInformationCacheSize = 256
 
 type Pools struct {
 	pools []*pool
 }
 
-// Create new pools
-func NewPools(tcpFilter, udpFilter []uint16, tcpDropIncomplete bool) *Pools {
+// Create new pools.
+// Only flows matching the flowFilter expression (see filter.go) are passed to the metrics. An empty expression matches all flows.
+func NewPools(tcpFilter, udpFilter []uint16, tcpDropIncomplete bool, flowFilterExpression string) *Pools {
 	p := &Pools{}
+	flowFilter, err := parseFlowFilter(flowFilterExpression)
+	if err != nil {
+		log.Fatalln("Invalid flow filter", flowFilterExpression, ":", err)
+	}
 	var tcpFilterList [65536]bool
 	for _, i := range tcpFilter {
 		tcpFilterList[i] = true
@@ -37,7 +44,7 @@ func NewPools(tcpFilter, udpFilter []uint16, tcpDropIncomplete bool) *Pools {
 	}
 	p.pools = make([]*pool, numFlowThreads)
 	for i := 0; i < numFlowThreads; i++ {
-		p.pools[i] = newPool(&tcpFilterList, &udpFilterList, tcpDropIncomplete)
+		p.pools[i] = newPool(&tcpFilterList, &udpFilterList, tcpDropIncomplete, flowFilter)
 	}
 	return p
 }
@@ -66,6 +73,18 @@ func (p *Pools) AddUDPPacket(packet *flows.PacketInformation) {
 	p.pools[poolIndex].addUDPPacket(packet)
 }
 
+// Add an SCTP Packet to the pools
+func (p *Pools) AddSCTPPacket(packet *flows.PacketInformation) {
+	poolIndex := uint64(packet.FlowKey) % numFlowThreads
+	p.pools[poolIndex].addSCTPPacket(packet)
+}
+
+// Add an ICMP or ICMPv6 Packet to the pools
+func (p *Pools) AddICMPPacket(packet *flows.PacketInformation) {
+	poolIndex := uint64(packet.FlowKey) % numFlowThreads
+	p.pools[poolIndex].addICMPPacket(packet)
+}
+
 // Flush out closed or timedout flows.
 // If force is true, all Flows are flushed, else only timedout flows
 func (p *Pools) Flush(force bool) {
@@ -74,16 +93,51 @@ func (p *Pools) Flush(force bool) {
 	var tcpCount int64
 	var udpFlushed int64
 	var udpCount int64
+	var sctpFlushed int64
+	var sctpCount int64
+	var icmpFlushed int64
+	var icmpCount int64
 	var counterLock sync.Mutex
 	for _, pool := range p.pools {
-		pool.flush(force, &wgFlush, &tcpFlushed, &tcpCount, &udpFlushed, &udpCount, &counterLock)
+		pool.flush(force, &wgFlush, &tcpFlushed, &tcpCount, &udpFlushed, &udpCount, &sctpFlushed, &sctpCount, &icmpFlushed, &icmpCount, &counterLock)
 	}
 	wgFlush.Wait()
 	fmt.Println(humanize.Comma(tcpFlushed), "\t/", humanize.Comma(tcpCount), "TCP Flows flushed")
 	fmt.Println(humanize.Comma(udpFlushed), "\t/", humanize.Comma(udpCount), "UDP Flows flushed")
+	fmt.Println(humanize.Comma(sctpFlushed), "\t/", humanize.Comma(sctpCount), "SCTP Flows flushed")
+	fmt.Println(humanize.Comma(icmpFlushed), "\t/", humanize.Comma(icmpCount), "ICMP Flows flushed")
 	wgFlush.Wait()
 }
 
+// Drain blocks until all packets passed to the pools have been added to their flows.
+// Must not be called concurrently with adding packets, nor while the pools are paused.
+func (p *Pools) Drain() {
+	for _, pool := range p.pools {
+		pool.drain()
+	}
+}
+
+// Pause blocks the goroutines adding packets to the pools, so that no flow is flushed to the metrics until Resume is called.
+// Packets are buffered in the channels meanwhile.
+func (p *Pools) Pause() {
+	for _, pool := range p.pools {
+		pool.tcpFlowsLock.Lock()
+		pool.udpFlowsLock.Lock()
+		pool.sctpFlowsLock.Lock()
+		pool.icmpFlowsLock.Lock()
+	}
+}
+
+// Resume continues adding packets after Pause
+func (p *Pools) Resume() {
+	for _, pool := range p.pools {
+		pool.tcpFlowsLock.Unlock()
+		pool.udpFlowsLock.Unlock()
+		pool.sctpFlowsLock.Unlock()
+		pool.icmpFlowsLock.Unlock()
+	}
+}
+
 // Close all pools and flush out all flows from pools.
 func (p *Pools) Close() {
 	for _, pool := range p.pools {
@@ -98,9 +152,13 @@ func (p *Pools) PrintStatistics() {
 	var numTCPPackets int64
 	var numUDPFlows int64
 	var numUDPPackets int64
+	var numSCTPFlows int64
+	var numSCTPPackets int64
+	var numICMPFlows int64
+	var numICMPPackets int64
 	var counterLock sync.Mutex
 	for _, pool := range p.pools {
-		pool.printStatistics(&numTCPFlows, &numTCPPackets, &numUDPFlows, &numUDPPackets, &counterLock)
+		pool.printStatistics(&numTCPFlows, &numTCPPackets, &numUDPFlows, &numUDPPackets, &numSCTPFlows, &numSCTPPackets, &numICMPFlows, &numICMPPackets, &counterLock)
 	}
 
 	fmt.Println("Number of TCP Flows in Pool:\t", humanize.Comma(numTCPFlows))
@@ -108,4 +166,10 @@ func (p *Pools) PrintStatistics() {
 
 	fmt.Println("Number of UDP Flows in Pool:\t", humanize.Comma(numUDPFlows))
 	fmt.Println("Number of UDP Packets in Pool:\t", humanize.Comma(numUDPPackets))
+
+	fmt.Println("Number of SCTP Flows in Pool:\t", humanize.Comma(numSCTPFlows))
+	fmt.Println("Number of SCTP Packets in Pool:\t", humanize.Comma(numSCTPPackets))
+
+	fmt.Println("Number of ICMP Flows in Pool:\t", humanize.Comma(numICMPFlows))
+	fmt.Println("Number of ICMP Packets in Pool:\t", humanize.Comma(numICMPPackets))
 }
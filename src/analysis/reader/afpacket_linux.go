@@ -0,0 +1,145 @@
+package reader
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/afpacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+	"golang.org/x/net/bpf"
+)
+
+// afpacketFrameSize is the frame size of the ring. With TPACKET_V3 frames have a variable length,
+// so it only has to divide the block size.
+const afpacketFrameSize = 4096
+
+// AFPacketSource is a PacketDataSource capturing packets with an AF_PACKET socket and a TPACKET_V3 memory mapped ring.
+type AFPacketSource struct {
+	handle   *afpacket.TPacket
+	linkType layers.LinkType
+}
+
+// NewAFPacketSource opens an AF_PACKET socket on the interface.
+// blockSize (multiple of the page size) and numBlocks define the size of the ring.
+// If fanoutGroup is not negative, the socket joins the fanout group and the kernel distributes the packets between
+// all sockets (e.g. multiple analyzer processes) of the group by the fanoutMode (hash, lb, cpu, rollover, random, qm).
+// Use hash to keep both directions of a flow in the same socket.
+func NewAFPacketSource(interfaceName string, blockSize, numBlocks, fanoutGroup int, fanoutMode string) *AFPacketSource {
+	handle, err := afpacket.NewTPacket(
+		afpacket.OptInterface(interfaceName),
+		afpacket.OptFrameSize(afpacketFrameSize),
+		afpacket.OptBlockSize(blockSize),
+		afpacket.OptNumBlocks(numBlocks),
+		afpacket.OptPollTimeout(LiveReadTimeout),
+		afpacket.TPacketVersion3,
+	)
+	if err != nil {
+		log.Fatalln("Could not open AF_PACKET socket on interface", interfaceName, ":", err)
+	}
+	if fanoutGroup >= 0 {
+		fanoutType, err := getFanoutType(fanoutMode)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if fanoutGroup > 0xffff {
+			log.Fatalln("Fanout group must be between 0 and 65535:", fanoutGroup)
+		}
+		if err = handle.SetFanout(fanoutType, uint16(fanoutGroup)); err != nil {
+			log.Fatalln("Could not join fanout group", fanoutGroup, ":", err)
+		}
+	}
+
+	return &AFPacketSource{handle: handle, linkType: getInterfaceLinkType(interfaceName)}
+}
+
+// ARPHRD types of interfaces (linux/if_arp.h), whose link layer differs from Ethernet
+const arphrdIEEE80211 = 801
+const arphrdIEEE80211Radiotap = 803
+const arphrdNone = 65534
+
+// getInterfaceLinkType returns the link type of the interface based on its ARPHRD type.
+// AF_PACKET delivers the link layer header of the interface, e.g. Ethernet headers for loopback and none for tun interfaces.
+func getInterfaceLinkType(interfaceName string) layers.LinkType {
+	arphrdType, err := os.ReadFile(path.Join("/sys/class/net", interfaceName, "type"))
+	if err != nil {
+		return layers.LinkTypeEthernet
+	}
+	switch strings.TrimSpace(string(arphrdType)) {
+	case strconv.Itoa(arphrdNone):
+		return layers.LinkTypeRaw
+	case strconv.Itoa(arphrdIEEE80211):
+		return layers.LinkTypeIEEE802_11
+	case strconv.Itoa(arphrdIEEE80211Radiotap):
+		return layers.LinkTypeIEEE80211Radio
+	default:
+		return layers.LinkTypeEthernet
+	}
+}
+
+// getFanoutType returns the afpacket.FanoutType of the fanout mode
+func getFanoutType(fanoutMode string) (afpacket.FanoutType, error) {
+	switch strings.ToLower(fanoutMode) {
+	case "hash":
+		return afpacket.FanoutHash, nil
+	case "lb", "loadbalance":
+		return afpacket.FanoutLoadBalance, nil
+	case "cpu":
+		return afpacket.FanoutCPU, nil
+	case "rollover":
+		return afpacket.FanoutRollover, nil
+	case "random":
+		return afpacket.FanoutRandom, nil
+	case "qm", "queue":
+		return afpacket.FanoutQueueMapping, nil
+	default:
+		return 0, fmt.Errorf("Unknown fanout mode: %s", fanoutMode)
+	}
+}
+
+// SetBPFFilter attaches the BPF filter expression (pcap-filter syntax) to the socket
+func (a *AFPacketSource) SetBPFFilter(expression string) error {
+	instructions, err := pcap.CompileBPFFilter(a.linkType, maxSnapLength, expression)
+	if err != nil {
+		return err
+	}
+	rawInstructions := make([]bpf.RawInstruction, len(instructions))
+	for i, instruction := range instructions {
+		rawInstructions[i] = bpf.RawInstruction{Op: instruction.Code, Jt: instruction.Jt, Jf: instruction.Jf, K: instruction.K}
+	}
+	return a.handle.SetBPF(rawInstructions)
+}
+
+// ReadPacketData reads the next packet. The data is copied out of the ring, since it is parsed asynchronously.
+// Returns errReadTimeout if no packet was received within the LiveReadTimeout.
+func (a *AFPacketSource) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	data, ci, err = a.handle.ReadPacketData()
+	if err == afpacket.ErrTimeout {
+		err = errReadTimeout
+	}
+	return data, ci, err
+}
+
+// LinkType returns the link type of the interface
+func (a *AFPacketSource) LinkType() layers.LinkType {
+	return a.linkType
+}
+
+// CaptureStatistics returns the number of packets received and dropped by the kernel since the socket was opened
+func (a *AFPacketSource) CaptureStatistics() (received, dropped uint64, err error) {
+	_, statsV3, err := a.handle.SocketStats()
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint64(statsV3.Packets()), uint64(statsV3.Drops()), nil
+}
+
+// Close closes the socket
+func (a *AFPacketSource) Close() {
+	a.handle.Close()
+}
@@ -0,0 +1,38 @@
+//go:build !linux
+
+package reader
+
+import (
+	"errors"
+	"log"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// AFPacketSource is only supported on Linux
+type AFPacketSource struct{}
+
+// NewAFPacketSource aborts, since AF_PACKET sockets are only supported on Linux
+func NewAFPacketSource(interfaceName string, blockSize, numBlocks, fanoutGroup int, fanoutMode string) *AFPacketSource {
+	log.Fatalln("The afpacket capture backend is only supported on Linux")
+	return nil
+}
+
+func (a *AFPacketSource) SetBPFFilter(expression string) error {
+	return errors.New("not supported")
+}
+
+func (a *AFPacketSource) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	return nil, gopacket.CaptureInfo{}, errors.New("not supported")
+}
+
+func (a *AFPacketSource) LinkType() layers.LinkType {
+	return layers.LinkTypeEthernet
+}
+
+func (a *AFPacketSource) CaptureStatistics() (received, dropped uint64, err error) {
+	return 0, 0, errors.New("not supported")
+}
+
+func (a *AFPacketSource) Close() {}
@@ -0,0 +1,78 @@
+package reader
+
+import (
+	"scalable-flow-analyzer/utils"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"io"
+	"log"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	gzip "github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
+)
+
+// decompressedFile is the decompressed stream of a file. Closing it closes the decompressor and the file.
+type decompressedFile struct {
+	io.Reader
+	file         *os.File
+	decompressor io.Closer // nil, if the decompressor does not need to be closed
+}
+
+func (d *decompressedFile) Close() error {
+	if d.decompressor != nil {
+		_ = d.decompressor.Close()
+	}
+	return d.file.Close()
+}
+
+// Magic bytes of the supported compression formats
+var magicGzip = []byte{0x1f, 0x8b}
+var magicBzip2 = []byte("BZh")
+var magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+var magicXz = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+
+// openFile opens the file (utils.StdinFilename for stdin, also named pipes are supported) and decompresses it on the fly.
+// The compression format (gzip, bzip2, zstd or xz) is detected from the magic bytes.
+// The returned io.ReadCloser must be closed after the file has been read.
+func openFile(filename string) (*bufio.Reader, io.ReadCloser) {
+	var file = os.Stdin
+	if filename != utils.StdinFilename {
+		var err error
+		if file, err = os.Open(filename); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var decompressed = &decompressedFile{file: file}
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(len(magicXz))
+	var err error
+	switch {
+	case bytes.HasPrefix(magic, magicGzip):
+		var gzipReader *gzip.Reader
+		gzipReader, err = gzip.NewReader(buffered)
+		decompressed.Reader = gzipReader
+		decompressed.decompressor = gzipReader
+	case bytes.HasPrefix(magic, magicBzip2):
+		decompressed.Reader = bzip2.NewReader(buffered)
+	case bytes.HasPrefix(magic, magicZstd):
+		var zstdReader *zstd.Decoder
+		zstdReader, err = zstd.NewReader(buffered)
+		if err == nil {
+			decompressed.Reader = zstdReader
+			decompressed.decompressor = zstdReader.IOReadCloser()
+		}
+	case bytes.HasPrefix(magic, magicXz):
+		decompressed.Reader, err = xz.NewReader(buffered)
+	default:
+		// Not compressed
+		return buffered, decompressed
+	}
+	if err != nil {
+		log.Fatal("Could not decompress ", filename, ": ", err)
+	}
+	return bufio.NewReader(decompressed.Reader), decompressed
+}
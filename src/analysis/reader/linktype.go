@@ -0,0 +1,142 @@
+package reader
+
+// This file contains the link type handling of pcap and pcapng files.
+// gopacket truncates the DLT of the file header and of pcapng interface description blocks to 8 bits,
+// therefore the DLTs are read from the raw headers and mapped with parser.LinkTypeFromDLT.
+
+import (
+	"scalable-flow-analyzer/parser"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"log"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// pcapHeaderLength is the length of the pcap file header, which ends with the DLT
+const pcapHeaderLength = 24
+
+// pcapFileReader is a pcapgo.Reader, which returns the link type of the raw DLT
+type pcapFileReader struct {
+	*pcapgo.Reader
+	linkType layers.LinkType
+}
+
+// LinkType returns the link type of the file
+func (r *pcapFileReader) LinkType() layers.LinkType {
+	return r.linkType
+}
+
+// newPcapFileReader creates a pcapgo.Reader and reports an unsupported link type of the file
+func newPcapFileReader(filename string, ioReader *bufio.Reader) (*pcapFileReader, error) {
+	header, err := ioReader.Peek(pcapHeaderLength)
+	if err != nil {
+		return nil, err
+	}
+	var byteOrder binary.ByteOrder = binary.LittleEndian
+	if header[0] == 0xa1 {
+		byteOrder = binary.BigEndian
+	}
+	// The upper 16 bits contain the FCS length
+	dlt := byteOrder.Uint32(header[20:24]) & 0xffff
+
+	reader, err := pcapgo.NewReader(ioReader)
+	if err != nil {
+		return nil, err
+	}
+	linkType := parser.LinkTypeFromDLT(dlt)
+	if !parser.IsLinkTypeSupported(linkType) {
+		log.Println(filename, ": Unsupported link type", dlt, ": Its packets are ignored")
+	}
+	return &pcapFileReader{Reader: reader, linkType: linkType}, nil
+}
+
+// pcapng block types
+const ngBlockTypeInterfaceDescription = 1
+
+// ngBlockHeaderLength is the length of the block type, the block length and the DLT of interface description blocks
+const ngBlockHeaderLength = 12
+
+// ngLinkTypeReader passes a pcapng file to pcapgo.NgReader and replaces the DLT of every interface description block
+// by its link type (see parser.LinkTypeFromDLT). Unsupported link types are reported with the file name.
+type ngLinkTypeReader struct {
+	reader   *bufio.Reader
+	filename string
+	// Byte order of the current section, nil if the file is malformed (it is passed on unchanged)
+	byteOrder binary.ByteOrder
+	// Number of interfaces of the current section
+	numInterfaces int
+	// Position in the current block
+	blockOffset    uint32
+	blockRemaining uint32
+	// Replaced DLT of the current block, if it is an interface description block
+	replaceLinkType bool
+	linkType        [2]byte
+}
+
+func (r *ngLinkTypeReader) Read(p []byte) (int, error) {
+	if r.blockRemaining == 0 {
+		r.startBlock()
+	}
+	if r.blockRemaining > 0 && uint32(len(p)) > r.blockRemaining {
+		p = p[:r.blockRemaining]
+	}
+	n, err := r.reader.Read(p)
+	if r.replaceLinkType {
+		// The DLT is stored in the first two bytes after the block type and the block length
+		for i := range r.linkType {
+			if offset := 8 + uint32(i); offset >= r.blockOffset && offset < r.blockOffset+uint32(n) {
+				p[offset-r.blockOffset] = r.linkType[i]
+			}
+		}
+	}
+	r.blockOffset += uint32(n)
+	if r.blockRemaining > 0 {
+		r.blockRemaining -= uint32(n)
+	}
+	return n, err
+}
+
+// startBlock reads the header of the next block
+func (r *ngLinkTypeReader) startBlock() {
+	r.blockOffset = 0
+	r.replaceLinkType = false
+	header, _ := r.reader.Peek(ngBlockHeaderLength)
+	if len(header) < ngBlockHeaderLength {
+		r.byteOrder = nil
+		return
+	}
+	if bytes.Equal(header[:4], magicPcapNg) {
+		// Section header block: The byte-order magic follows the block length
+		switch binary.BigEndian.Uint32(header[8:12]) {
+		case 0x1a2b3c4d:
+			r.byteOrder = binary.BigEndian
+		case 0x4d3c2b1a:
+			r.byteOrder = binary.LittleEndian
+		default:
+			r.byteOrder = nil
+		}
+		r.numInterfaces = 0
+	}
+	if r.byteOrder == nil {
+		return
+	}
+	blockLength := r.byteOrder.Uint32(header[4:8])
+	if blockLength < ngBlockHeaderLength {
+		r.byteOrder = nil
+		return
+	}
+	r.blockRemaining = blockLength
+	if r.byteOrder.Uint32(header[:4]) == ngBlockTypeInterfaceDescription {
+		dlt := r.byteOrder.Uint16(header[8:10])
+		linkType := parser.LinkTypeFromDLT(uint32(dlt))
+		if !parser.IsLinkTypeSupported(linkType) {
+			log.Println(r.filename, ": Unsupported link type", dlt, "of interface", r.numInterfaces, ": Its packets are ignored")
+		}
+		r.byteOrder.PutUint16(r.linkType[:], uint16(linkType))
+		r.replaceLinkType = true
+		r.numInterfaces++
+	}
+}
@@ -0,0 +1,145 @@
+package reader
+
+import (
+	"container/heap"
+	"io"
+	"log"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// mergeSource is a PacketDataSource of the merge with its next packet
+type mergeSource struct {
+	source PacketDataSource
+	data   []byte
+	ci     gopacket.CaptureInfo
+	// Global interface index of each interface ID of this source
+	interfaces []int
+}
+
+// MergedPacketDataSource merges the packets of several PacketDataSources by their timestamp (k-way merge).
+// Interface IDs are only unique within a source, so every interface of every source gets a global interface index.
+// The link type of every packet is passed in the AncillaryData (like pcapgo.NgReader does).
+type MergedPacketDataSource struct {
+	sources        mergeHeap
+	interfaceNames []string // Name of each global interface index
+}
+
+// NewMergedPacketDataSource creates a MergedPacketDataSource. Each source must deliver its packets in timestamp order.
+func NewMergedPacketDataSource(sources []PacketDataSource) *MergedPacketDataSource {
+	merged := &MergedPacketDataSource{}
+	for _, source := range sources {
+		mergeSource := &mergeSource{source: source}
+		if merged.readNext(mergeSource) {
+			merged.sources = append(merged.sources, mergeSource)
+		}
+	}
+	heap.Init(&merged.sources)
+	return merged
+}
+
+// ReadPacketData returns the packet with the lowest timestamp of all sources
+func (m *MergedPacketDataSource) ReadPacketData() (data []byte, ci gopacket.CaptureInfo, err error) {
+	if len(m.sources) == 0 {
+		return nil, gopacket.CaptureInfo{}, io.EOF
+	}
+	next := m.sources[0]
+	data, ci = next.data, next.ci
+	if m.readNext(next) {
+		heap.Fix(&m.sources, 0)
+	} else {
+		heap.Pop(&m.sources)
+	}
+	return data, ci, nil
+}
+
+// LinkType returns the link type of the first source. The link type of each packet is passed in the AncillaryData.
+func (m *MergedPacketDataSource) LinkType() layers.LinkType {
+	if len(m.sources) == 0 {
+		return layers.LinkTypeEthernet
+	}
+	return m.sources[0].source.LinkType()
+}
+
+// InterfaceName returns the name of the interface with the global interface index
+func (m *MergedPacketDataSource) InterfaceName(interfaceIndex int) string {
+	if interfaceIndex >= len(m.interfaceNames) {
+		return ""
+	}
+	return m.interfaceNames[interfaceIndex]
+}
+
+// readNext reads the next packet of the source. Returns false if the source is depleted.
+// Sources with read errors are treated as depleted, since the packets of a stream cannot be found after an error.
+func (m *MergedPacketDataSource) readNext(source *mergeSource) bool {
+	data, ci, err := source.source.ReadPacketData()
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		log.Println("Error reading packet, stop reading the source:", err)
+		return false
+	}
+
+	linkType := source.source.LinkType()
+	if len(ci.AncillaryData) > 0 {
+		if interfaceLinkType, ok := ci.AncillaryData[0].(layers.LinkType); ok {
+			linkType = interfaceLinkType
+		}
+	}
+	ci.AncillaryData = []interface{}{linkType}
+	ci.InterfaceIndex = m.getGlobalInterfaceIndex(source, ci.InterfaceIndex)
+
+	source.data = data
+	source.ci = ci
+	return true
+}
+
+// getGlobalInterfaceIndex returns the global interface index of the interface of the source
+func (m *MergedPacketDataSource) getGlobalInterfaceIndex(source *mergeSource, interfaceIndex int) int {
+	for interfaceIndex >= len(source.interfaces) {
+		source.interfaces = append(source.interfaces, -1)
+	}
+	if source.interfaces[interfaceIndex] == -1 {
+		source.interfaces[interfaceIndex] = len(m.interfaceNames)
+		m.interfaceNames = append(m.interfaceNames, getInterfaceName(source.source, interfaceIndex))
+	}
+	return source.interfaces[interfaceIndex]
+}
+
+// getInterfaceName returns the name of the interface of the source, or an empty string if the name is unknown
+func getInterfaceName(source PacketDataSource, interfaceIndex int) string {
+	switch s := source.(type) {
+	case *pcapgo.NgReader:
+		if ngInterface, err := s.Interface(interfaceIndex); err == nil {
+			return ngInterface.Name
+		}
+	case *MergedPacketDataSource:
+		return s.InterfaceName(interfaceIndex)
+	}
+	return ""
+}
+
+// mergeHeap is a min-heap of the sources ordered by the timestamp of their next packet
+type mergeHeap []*mergeSource
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	return h[i].ci.Timestamp.Before(h[j].ci.Timestamp)
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*mergeSource))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
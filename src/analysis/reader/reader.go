@@ -4,16 +4,39 @@ import (
 	"scalable-flow-analyzer/parser"
 	"scalable-flow-analyzer/pool"
 	"scalable-flow-analyzer/utils"
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/dustin/go-humanize"
 	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
 	"github.com/google/gopacket/pcapgo"
 	"io"
 	"log"
-	"os"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// LiveReadTimeout is the time after which reads from live sources return without a packet,
+// so Read can be stopped even if no packets are received.
+const LiveReadTimeout = 500 * time.Millisecond
+
+// errReadTimeout is returned by live sources if no packet was received within the LiveReadTimeout
+var errReadTimeout = errors.New("read timeout expired")
+
+// StopConditions define when PacketReader.Read stops reading. Zero values are unlimited.
+type StopConditions struct {
+	MaxPackets int64 // Number of packets (after interface and BPF filter)
+	MaxBytes   int64 // Number of bytes on the wire
+	// Duration relative to the timestamp of the first packet (trace time)
+	MaxTraceDuration time.Duration
+	// Duration relative to the first call of Read (wall-clock time)
+	MaxWallClockDuration time.Duration
+}
+
 // PacketReader reads from a source.
 // Is responsible for forwarding packets to the parser,
 // as well as keeping track of the number of Packet, as well
@@ -23,34 +46,149 @@ type PacketReader struct {
 	flushTimestamp       int64
 	FirstPacketTimestamp int64
 	LastPacketTimestamp  int64
+	NumBytes             int64 // Number of bytes on the wire of all packets read
 	pools                *pool.Pools
 	parser               *parser.Parser
+
+	// Capture interfaces (ID or name) to analyze, nil analyzes all interfaces
+	interfaceFilter []string
+	// Filter result of each interface ID of the current source
+	interfaceAccepted []bool
+	interfaceChecked  []bool
+
+	// BPF filter expression for offline sources and its compiled filter for each link type
+	bpfFilter  string
+	bpfFilters map[layers.LinkType]*pcap.BPF
+	// Number of packets rejected by the BPF filter
+	NumBPFRejected int64
+
+	stopConditions StopConditions
+	wallClockTimer *time.Timer
+
+	// Rotation interval in nanoseconds, start of the current interval and callback for finished intervals
+	rotationInterval int64
+	rotationStart    int64
+	onRotate         func(intervalStart int64)
+	// Set by Stop (accessed atomically)
+	stopped int32
+}
+
+// PacketDataSource is a gopacket.PacketDataSource, which knows the link type of its packets.
+// The readers returned by ReadPcapFile, pcap.Handle and AFPacketSource implement it.
+type PacketDataSource interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+// captureStatisticsSource is implemented by live sources, which report the packets received and dropped by the kernel
+type captureStatisticsSource interface {
+	CaptureStatistics() (received, dropped uint64, err error)
 }
 
 // NewPacketReader creates a new PacketReader.
-func NewPacketReader(pools *pool.Pools, packetParser *parser.Parser) *PacketReader {
-	return &PacketReader{
-		pools:  pools,
-		parser: packetParser,
+// If interfaceFilter is not empty, only packets captured on these interfaces (pcapng interface ID or name) are analyzed.
+func NewPacketReader(pools *pool.Pools, packetParser *parser.Parser, interfaceFilter []string) *PacketReader {
+	packetReader := &PacketReader{
+		pools:      pools,
+		parser:     packetParser,
+		bpfFilters: make(map[layers.LinkType]*pcap.BPF),
+	}
+	for _, captureInterface := range interfaceFilter {
+		if captureInterface = strings.TrimSpace(captureInterface); captureInterface != "" {
+			packetReader.interfaceFilter = append(packetReader.interfaceFilter, captureInterface)
+		}
 	}
+	return packetReader
+}
+
+// SetBPFFilter sets a BPF filter expression (pcap-filter syntax), which is applied to all packets read by Read.
+// The filter is compiled for the link type of each packet.
+// Live captures should be filtered in the kernel with pcap.Handle.SetBPFFilter instead.
+func (p *PacketReader) SetBPFFilter(expression string) {
+	p.bpfFilter = strings.TrimSpace(expression)
+}
+
+// SetStopConditions sets the conditions after which Read stops reading packets
+func (p *PacketReader) SetStopConditions(stopConditions StopConditions) {
+	p.stopConditions = stopConditions
+}
+
+// SetRotation calls onRotate with the start of the finished interval (in nanoseconds),
+// whenever a packet timestamp reaches the next rotation interval.
+// For live sources, the wall-clock time is checked as well when no packet was received within the read timeout.
+// Intervals are aligned to multiples of the interval, e.g. full hours.
+func (p *PacketReader) SetRotation(interval time.Duration, onRotate func(intervalStart int64)) {
+	p.rotationInterval = int64(interval)
+	p.onRotate = onRotate
+}
+
+// RotationIntervalStart returns the start of the current rotation interval in nanoseconds (0 if no packet was read)
+func (p *PacketReader) RotationIntervalStart() int64 {
+	return p.rotationStart
 }
 
 // Read more packets from the provided source.
 // Will stop either when the source is depleted
-// or when the specified number of packets have been read.
+// or when a stop condition (see SetStopConditions) is reached.
 // Use first return value of ReadPCAPFile to read a pcap file.
 // flushRate specifies the time in nanoseconds after which pools will be flushed.
 // Flushing the pool is necessary to remove timedout flows from the pool
 // and to keep memory footprint low.
 //
-// Returns whether the specified number of packets have been read
-func (p *PacketReader) Read(packetStop, flushRate int64, packetDataSource gopacket.PacketDataSource) bool {
-	for p.PacketIdx < packetStop {
+// Returns whether a stop condition has been reached or Stop was called
+func (p *PacketReader) Read(flushRate int64, packetDataSource PacketDataSource) bool {
+	sourceLinkType := packetDataSource.LinkType()
+	// Interface IDs are only valid within a source
+	p.interfaceAccepted = p.interfaceAccepted[:0]
+	p.interfaceChecked = p.interfaceChecked[:0]
+	if p.stopConditions.MaxWallClockDuration > 0 && p.wallClockTimer == nil {
+		p.wallClockTimer = time.AfterFunc(p.stopConditions.MaxWallClockDuration, func() {
+			log.Println("Maximum duration (wall-clock time) reached")
+			p.Stop()
+		})
+	}
+	for {
+		if atomic.LoadInt32(&p.stopped) != 0 {
+			return true
+		}
+		if p.stopConditions.MaxPackets > 0 && p.PacketIdx >= p.stopConditions.MaxPackets {
+			log.Println("Maximum number of packets reached")
+			return true
+		}
 		data, ci, err := packetDataSource.ReadPacketData()
 		// Stop reading at end of file
 		if err == io.EOF {
 			return false
 		}
+		// No packet received by a live source, check whether reading was stopped
+		if err == pcap.NextErrorTimeoutExpired || err == errReadTimeout {
+			// Packets of live sources are timestamped with the wall-clock time,
+			// so the rotation interval also ends on a quiet link
+			if p.rotationInterval > 0 && p.rotationStart != 0 {
+				p.rotate(time.Now().UnixNano())
+			}
+			continue
+		}
+		// pcapng files can contain interfaces with different link types
+		linkType := sourceLinkType
+		if len(ci.AncillaryData) > 0 {
+			if interfaceLinkType, ok := ci.AncillaryData[0].(layers.LinkType); ok {
+				linkType = interfaceLinkType
+			}
+		}
+		if err == nil && !p.acceptPacket(linkType, ci.InterfaceIndex, packetDataSource) {
+			continue
+		}
+		if err == nil && p.bpfFilter != "" {
+			if bpfFilter := p.getBPFFilter(linkType); bpfFilter != nil && !bpfFilter.Matches(ci, data) {
+				p.NumBPFRejected++
+				continue
+			}
+		}
+		if err == nil && p.isStopConditionReached(ci) {
+			return true
+		}
+		p.NumBytes += int64(ci.Length)
 		// Setup Flushing Interval
 		p.LastPacketTimestamp = ci.Timestamp.UnixNano()
 		if p.PacketIdx == 0 {
@@ -62,87 +200,177 @@ func (p *PacketReader) Read(packetStop, flushRate int64, packetDataSource gopack
 			fmt.Println("Error reading packet: ", err)
 			continue
 		}
+		if p.rotationInterval > 0 {
+			p.rotate(p.LastPacketTimestamp)
+		}
 		// Parse packet
-		p.parser.ParsePacket(data, p.PacketIdx, p.LastPacketTimestamp)
+		p.parser.ParsePacket(data, p.PacketIdx, p.LastPacketTimestamp, linkType, uint32(ci.InterfaceIndex))
 		// Flush packet when flushing interval is reached
 		if p.LastPacketTimestamp > p.flushTimestamp {
 			p.flushTimestamp = p.LastPacketTimestamp + flushRate
 			utils.PrintMemUsage()
 			fmt.Println("Flush at packet", humanize.Comma(p.PacketIdx))
+			PrintCaptureStatistics(packetDataSource)
 			p.pools.Flush(false)
 		}
 	}
-	return true
 }
 
-// ReadPcapFile reads a pcap/pcapng file from filename. This file can optionally be zipped.
-//
-// Returns an instance of NgReader to read the pcap.
-// Also returns an io.ReadCloser which must be closed after the file has been read.
-// file is not nil for zipped file. If it is not nil, the caller must close it.
-func ReadPcapFile(filename string) (reader gopacket.PacketDataSource, ioReader io.ReadCloser, deleteFile bool, deleteFileName string) {
-	if strings.Contains(filename, ".pcapng") {
-		return readPcapNgFile(filename)
-	} else {
-		return readPcapFile(filename)
+// rotate calls the rotation callback, if the timestamp is beyond the current rotation interval.
+// The packets read before are added to the pools (see parser.Parser.Drain) and the pools are flushed before,
+// so that all packets and timed out flows are attributed to the finished interval.
+// The pools are paused during the callback, so that no flow is flushed between exporting and resetting the metrics.
+func (p *PacketReader) rotate(timestamp int64) {
+	intervalStart := timestamp - timestamp%p.rotationInterval
+	if p.rotationStart == 0 {
+		p.rotationStart = intervalStart
+		return
+	}
+	if intervalStart <= p.rotationStart {
+		return
 	}
+	fmt.Println("Rotate at packet", humanize.Comma(p.PacketIdx))
+	// Packets of the finished interval may still be queued in the parser
+	p.parser.Drain()
+	p.pools.Flush(false)
+	p.pools.Pause()
+	p.onRotate(p.rotationStart)
+	p.pools.Resume()
+	p.rotationStart = intervalStart
 }
 
-// readPcapFile reads a pcap file from filename. This file can optionally be zipped.
-//
-// Returns an instance of NgReader to read the pcap.
-// Also returns an io.ReadCloser which must be closed after the file has been read.
-// file is not nil for zipped file. If it is not nil, the caller must close it.
-func readPcapFile(filename string) (reader *pcapgo.Reader, ioReader io.ReadCloser, deleteFile bool, deleteFileName string) {
-	var err error
-
-	if utils.IsZipFile(filename) {
-		unzippedFileName := utils.Unzip(filename)
-		ioReader, err = os.Open(unzippedFileName)
-		deleteFile = true
-		deleteFileName = unzippedFileName
-	} else {
-		ioReader, err = os.Open(filename)
+// isStopConditionReached returns whether the packet exceeds the maximum number of bytes or the maximum trace duration.
+// The packet is not analyzed in this case.
+func (p *PacketReader) isStopConditionReached(ci gopacket.CaptureInfo) bool {
+	if p.stopConditions.MaxBytes > 0 && p.NumBytes+int64(ci.Length) > p.stopConditions.MaxBytes {
+		log.Println("Maximum number of bytes reached")
+		return true
+	}
+	if p.stopConditions.MaxTraceDuration > 0 && p.PacketIdx > 0 &&
+		ci.Timestamp.UnixNano()-p.FirstPacketTimestamp > int64(p.stopConditions.MaxTraceDuration) {
+		log.Println("Maximum duration (trace time) reached")
+		return true
 	}
+	return false
+}
 
-	if err != nil {
-		log.Fatal(err)
+// PrintCaptureStatistics prints the packets received and dropped by the kernel for live sources (pcap and AF_PACKET).
+// Nothing is printed for files.
+func PrintCaptureStatistics(packetDataSource PacketDataSource) {
+	var received, dropped uint64
+	switch source := packetDataSource.(type) {
+	case *pcap.Handle:
+		stats, err := source.Stats()
+		if err != nil {
+			log.Println("Could not get capture statistics:", err)
+			return
+		}
+		received = uint64(stats.PacketsReceived)
+		dropped = uint64(stats.PacketsDropped + stats.PacketsIfDropped)
+	case captureStatisticsSource:
+		var err error
+		received, dropped, err = source.CaptureStatistics()
+		if err != nil {
+			log.Println("Could not get capture statistics:", err)
+			return
+		}
+	default:
+		return
 	}
+	fmt.Println("Kernel received", humanize.Comma(int64(received)), "packets, dropped", humanize.Comma(int64(dropped)), "packets")
+}
 
-	reader, err = pcapgo.NewReader(ioReader)
+// Stop stops Read (and all following calls of Read) before the next packet.
+// Can be called from any goroutine, e.g. a signal handler.
+func (p *PacketReader) Stop() {
+	atomic.StoreInt32(&p.stopped, 1)
+}
 
-	if err != nil {
-		panic(err)
+// acceptPacket returns whether the packet has a supported link type and was captured on an interface of the interface filter.
+// Unsupported link types are reported when the source is opened (see ReadPcapFile).
+func (p *PacketReader) acceptPacket(linkType layers.LinkType, interfaceIndex int, packetDataSource PacketDataSource) bool {
+	if !parser.IsLinkTypeSupported(linkType) {
+		return false
 	}
-
-	return reader, ioReader, deleteFile, deleteFileName
+	if p.interfaceFilter == nil {
+		return true
+	}
+	for interfaceIndex >= len(p.interfaceChecked) {
+		p.interfaceChecked = append(p.interfaceChecked, false)
+		p.interfaceAccepted = append(p.interfaceAccepted, false)
+	}
+	if !p.interfaceChecked[interfaceIndex] {
+		p.interfaceChecked[interfaceIndex] = true
+		p.interfaceAccepted[interfaceIndex] = p.isInterfaceInFilter(interfaceIndex, packetDataSource)
+	}
+	return p.interfaceAccepted[interfaceIndex]
 }
 
-// readPcapNgFile reads a pcapng file from filename. This file can optionally be zipped.
-//
-// Returns an instance of NgReader to read the pcap.
-// Also returns an io.ReadCloser which must be closed after the file has been read.
-// file is not nil for zipped file. If it is not nil, the caller must close it.
-func readPcapNgFile(filename string) (ngReader *pcapgo.NgReader, ioReader io.ReadCloser, deleteFile bool, deleteFileName string) {
-	var err error
-
-	if utils.IsZipFile(filename) {
-		unzippedFileName := utils.Unzip(filename)
-		ioReader, err = os.Open(unzippedFileName)
-		deleteFile = true
-		deleteFileName = unzippedFileName
-	} else {
-		ioReader, err = os.Open(filename)
+// getBPFFilter returns the BPF filter compiled for the link type.
+// Returns nil if the filter cannot be compiled for the link type, e.g. for Linux cooked v2 captures,
+// since gopacket cannot store their DLT (see parser.LinkTypeLinuxSLL2). Such packets are not filtered, a warning is logged once.
+func (p *PacketReader) getBPFFilter(linkType layers.LinkType) *pcap.BPF {
+	bpfFilter, exists := p.bpfFilters[linkType]
+	if !exists {
+		var err error
+		bpfFilter, err = pcap.NewBPF(linkType, maxSnapLength, p.bpfFilter)
+		if err != nil {
+			log.Println("Could not compile BPF filter", p.bpfFilter, "for link type", linkType, ":", err, ": Its packets are not filtered")
+			bpfFilter = nil
+		}
+		p.bpfFilters[linkType] = bpfFilter
 	}
+	return bpfFilter
+}
 
-	if err != nil {
-		log.Fatal(err)
+// isInterfaceInFilter checks the interface ID and, for pcapng files, the interface name against the interface filter
+func (p *PacketReader) isInterfaceInFilter(interfaceIndex int, packetDataSource PacketDataSource) bool {
+	name := getInterfaceName(packetDataSource, interfaceIndex)
+	for _, filter := range p.interfaceFilter {
+		if filter == strconv.Itoa(interfaceIndex) || (name != "" && filter == name) {
+			return true
+		}
 	}
+	return false
+}
 
-	ngReader, err = pcapgo.NewNgReader(ioReader, pcapgo.DefaultNgReaderOptions)
+// maxSnapLength is the capture length used to compile BPF filters (maximum snapshot length of libpcap)
+const maxSnapLength = 262144
 
+// Magic numbers of pcap (microsecond and nanosecond resolution, both byte orders) and pcapng files
+var magicPcap = [][]byte{{0xa1, 0xb2, 0xc3, 0xd4}, {0xd4, 0xc3, 0xb2, 0xa1}, {0xa1, 0xb2, 0x3c, 0x4d}, {0x4d, 0x3c, 0xb2, 0xa1}}
+var magicPcapNg = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// ReadPcapFile reads a pcap/pcapng file from filename (utils.StdinFilename for stdin, named pipes are supported as well).
+// The format and the compression are detected from the magic bytes. Compressed files are decompressed on the fly.
+//
+// Unsupported link types are reported with the file name when the file header (or the pcapng interface) is read.
+//
+// Returns a PacketDataSource (pcapgo.Reader or pcapgo.NgReader) to read the packets.
+// Also returns an io.Closer which must be closed after the file has been read.
+func ReadPcapFile(filename string) (reader PacketDataSource, file io.Closer) {
+	ioReader, file := openFile(filename)
+	magic, err := ioReader.Peek(len(magicPcapNg))
+	if err != nil {
+		log.Fatal("Could not read ", filename, ": ", err)
+	}
+	if bytes.Equal(magic, magicPcapNg) {
+		// Packets of all interfaces are read, their link type is passed in the AncillaryData
+		linkTypeReader := &ngLinkTypeReader{reader: ioReader, filename: filename}
+		reader, err = pcapgo.NewNgReader(linkTypeReader, pcapgo.NgReaderOptions{WantMixedLinkType: true})
+	} else {
+		for _, magicNumber := range magicPcap {
+			if bytes.Equal(magic, magicNumber) {
+				reader, err = newPcapFileReader(filename, ioReader)
+				break
+			}
+		}
+		if reader == nil && err == nil {
+			log.Fatalf("%s is neither a pcap nor a pcapng file (magic number %x)", filename, magic)
+		}
+	}
 	if err != nil {
 		panic(err)
 	}
-	return ngReader, ioReader, deleteFile, deleteFileName
-}
\ No newline at end of file
+	return reader, file
+}
@@ -1,12 +1,9 @@
 package utils
 
 import (
-	"bufio"
 	"fmt"
-	gzip "github.com/klauspost/pgzip"
 	"log"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"sort"
@@ -42,9 +39,16 @@ func DirectoryExists(directoryPath string) bool {
 	return info.IsDir()
 }
 
+// StdinFilename is the input, which refers to stdin
+const StdinFilename = "-"
+
 // GetPcapFiles returns all pcap files specified
+// Directories are searched for (compressed) pcap and pcapng files and named pipes.
 func GetPcapFiles(input string) []string {
 	switch {
+	case input == StdinFilename:
+		fmt.Println("Use input from stdin")
+		return []string{input}
 	case FileExists(input):
 		fmt.Println("Use input File:", input)
 		return []string{input}
@@ -56,8 +60,8 @@ func GetPcapFiles(input string) []string {
 			if info.IsDir() || path.Clean(path.Dir(filepath)) != path.Clean(input) {
 				return nil
 			}
-			// Only append pcapng files
-			if strings.HasSuffix(info.Name(), ".pcapng") || strings.HasSuffix(info.Name(), ".pcapng.bz2") {
+			// Only append (compressed) pcap/pcapng files and named pipes
+			if isPcapFile(info.Name()) || info.Mode()&os.ModeNamedPipe != 0 {
 				files = append(files, filepath)
 			}
 			return nil
@@ -77,6 +81,19 @@ func GetPcapFiles(input string) []string {
 	}
 }
 
+// CompressionExtensions contains the file extensions of all compression formats, which are decompressed on the fly
+var CompressionExtensions = []string{".gz", ".bz2", ".zst", ".zstd", ".xz"}
+
+// isPcapFile checks whether a file is a (compressed) pcap or pcapng file by looking at the extension
+func isPcapFile(filename string) bool {
+	for _, extension := range append([]string{""}, CompressionExtensions...) {
+		if strings.HasSuffix(filename, ".pcap"+extension) || strings.HasSuffix(filename, ".pcapng"+extension) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetFilesInPath returns the complete path to all files in the inputDirectory which do have the required extension.
 // Skips subdirectories
 func GetFilesInPath(inputDirectory, extensionWithoutDot string) []string {
@@ -109,78 +126,3 @@ func GetFilename(filePath string, withExtension bool) string {
 	}
 	return filename[:strings.Index(filename, ".")]
 }
-
-// Returns whether the given file is a .bz2 zip file
-func IsZipFile(filename string) (isZipFile bool) {
-	if isBz2(filename) {
-		return true
-	}
-	if isGzip(filename) {
-		return true
-	}
-	return false
-}
-
-// Unzips a zip file and returns the filename of the unzipped file
-func Unzip(filename string) (unzippedFileName string) {
-	if isBz2(filename) {
-		return unzipBz2(filename)
-	}
-	if isGzip(filename) {
-		return unzipGzip(filename)
-	}
-
-	panic("File is neither a .b")
-}
-
-// Checks whether a file is a .bz2 file by looking at the extension
-func isBz2(filename string) bool {
-	return strings.HasSuffix(filename, ".bz2")
-}
-
-// Checks whether a file is a .gz file by looking at the extension
-func isGzip(filename string) bool {
-	return strings.HasSuffix(filename, ".gz")
-}
-
-// Unzips a .bz2 file and returns the name of the unzipped file
-func unzipBz2(filename string) (unzippedFileName string) {
-	var err error
-
-	cmd := exec.Command("lbunzip2", "-k", "-n", "64", filename)
-	err = cmd.Run()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return filename[:len(filename)-4]
-}
-
-// Unzips a .gz file and returns the name of the unzipped file
-func unzipGzip(filename string) (unzippedFileName string) {
-	file, err := os.Open(filename)
-	assertNoError(err)
-
-	r, err := gzip.NewReader(file)
-	assertNoError(err)
-
-	unzippedFileName = filename[:len(filename)-3]
-	f, err := os.Create(unzippedFileName)
-	assertNoError(err)
-
-	w := bufio.NewWriter(f)
-	_, err = r.WriteTo(w)
-	assertNoError(err)
-
-	err = file.Close()
-	assertNoError(err)
-
-	return unzippedFileName
-}
-
-// Helper function that only returns if err == nil
-func assertNoError(err error) {
-	if err != nil {
-		log.Fatal(err)
-	}
-}